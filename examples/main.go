@@ -10,21 +10,21 @@ func main() {
 	d := NewDevice(size, size)
 	d.ClearColorBuffer(BLACK)
 
-	eye := NewVector3(-1, 0, 1)
-	target := NewVector3(0, 0.1, 0)
-	c := NewCamera(eye, target, UnitY())
-	d.SetCamera(c)
+	bunny := LoadPly("examples/bunny/reconstruction/bun_zipper.ply")
+	bunny.Normalize()
+	bunny.CalcNormal()
+	bunny.SmoothNormals()
 
-	d.Perspective(10, 1.0, 1, 10)
+	c := NewCamera(NewVector3(-1, 0, 1), Zero(), UnitY())
+	c.Projection = NewPerspectiveProjection(30, 0.1, 10)
+	FrameMesh(&c, bunny, d.Aspect())
+	d.SetCamera(c)
 
 	cl := NewColor(0.5, 1, 0.6, 1)
 	light := NewVector3(1, 0, 1)
-	shader := NewPhongShader(light, eye, cl, 64)
+	shader := NewPhongShader(light, c.Position, cl, 64)
 	d.SetShader(shader)
 
-	bunny := LoadPly("examples/bunny/reconstruction/bun_zipper.ply")
-	bunny.CalcNormal()
-	bunny.SmoothNormals()
 	d.DrawMesh(bunny)
 
 	Save("out.png", d.Image())
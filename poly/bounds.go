@@ -0,0 +1,146 @@
+package poly
+
+import (
+	"math"
+
+	. "github.com/arata-nvm/poly/vecmath"
+)
+
+// 以下の値はすべてモデル座標系(Position/Rotation/Scaleを適用する前)で計算する
+
+func (m *Mesh) TriangleCount() int {
+	return len(m.Faces)
+}
+
+func (m *Mesh) UniqueVertexCount() int {
+	seen := make(map[Vector3]struct{})
+	for _, f := range m.Faces {
+		seen[f.V1.Coordinates] = struct{}{}
+		seen[f.V2.Coordinates] = struct{}{}
+		seen[f.V3.Coordinates] = struct{}{}
+	}
+	return len(seen)
+}
+
+func (m *Mesh) BoundingBox() AABB {
+	b := EmptyAABB()
+	for _, f := range m.Faces {
+		b = b.Extend(f.V1.Coordinates).Extend(f.V2.Coordinates).Extend(f.V3.Coordinates)
+	}
+	return b
+}
+
+func (m *Mesh) WorldBoundingBox() AABB {
+	return m.BoundingBox().Transform(m.ModelMatrix())
+}
+
+// Ritterの方法による近似的な最小包含球
+func (m *Mesh) BoundingSphere() Sphere {
+	if len(m.Faces) == 0 {
+		return NewSphere(Zero(), 0)
+	}
+
+	farthest := func(from Vector3) Vector3 {
+		best, bestDist := from, -1.0
+		for _, f := range m.Faces {
+			for _, p := range []Vector3{f.V1.Coordinates, f.V2.Coordinates, f.V3.Coordinates} {
+				if d := p.Sub(from).LengthSq(); d > bestDist {
+					best, bestDist = p, d
+				}
+			}
+		}
+		return best
+	}
+
+	y := farthest(m.Faces[0].V1.Coordinates)
+	z := farthest(y)
+	center := y.Add(z).MulScalar(0.5)
+	radius := z.Sub(y).Length() / 2
+
+	for _, f := range m.Faces {
+		for _, p := range []Vector3{f.V1.Coordinates, f.V2.Coordinates, f.V3.Coordinates} {
+			d := p.Sub(center).Length()
+			if d <= radius {
+				continue
+			}
+			newRadius := (radius + d) / 2
+			center = center.Add(p.Sub(center).MulScalar((newRadius - radius) / d))
+			radius = newRadius
+		}
+	}
+
+	return NewSphere(center, radius)
+}
+
+func (m *Mesh) WorldBoundingSphere() Sphere {
+	return m.BoundingSphere().Transform(m.ModelMatrix())
+}
+
+func (m *Mesh) SurfaceArea() float64 {
+	area := 0.0
+	for _, f := range m.Faces {
+		area += f.Area()
+	}
+	return area
+}
+
+// 閉じたメッシュで外向きの巻き順なら正になる
+func (m *Mesh) Volume() float64 {
+	volume := 0.0
+	for _, f := range m.Faces {
+		volume += f.V1.Coordinates.Dot(f.V2.Coordinates.Cross(f.V3.Coordinates)) / 6
+	}
+	return volume
+}
+
+// 閉じたメッシュなら体積の重心、そうでなければ面積で重み付けした表面の重心を返す
+func (m *Mesh) Centroid() Vector3 {
+	volume := 0.0
+	c := Zero()
+	for _, f := range m.Faces {
+		a, b, d := f.V1.Coordinates, f.V2.Coordinates, f.V3.Coordinates
+		v := a.Dot(b.Cross(d)) / 6
+		volume += v
+		c = c.Add(a.Add(b).Add(d).MulScalar(v / 4))
+	}
+	if math.Abs(volume) > 1e-12 {
+		return c.DivScalar(volume)
+	}
+
+	area := 0.0
+	c = Zero()
+	for _, f := range m.Faces {
+		a := f.Area()
+		area += a
+		c = c.Add(f.V1.Coordinates.Add(f.V2.Coordinates).Add(f.V3.Coordinates).MulScalar(a / 3))
+	}
+	if area == 0 {
+		return m.BoundingBox().Center()
+	}
+	return c.DivScalar(area)
+}
+
+// 原点を中心とした一辺1の立方体に収まるように頂点を移動・拡大する
+func (m *Mesh) Normalize() {
+	b := m.BoundingBox()
+	if b.IsEmpty() {
+		return
+	}
+
+	center := b.Center()
+	size := b.Size()
+	extent := math.Max(size.X, math.Max(size.Y, size.Z))
+	scale := 1.0
+	if extent > 0 {
+		scale = 1 / extent
+	}
+
+	for _, f := range m.Faces {
+		f.V1.Coordinates = f.V1.Coordinates.Sub(center).MulScalar(scale)
+		f.V2.Coordinates = f.V2.Coordinates.Sub(center).MulScalar(scale)
+		f.V3.Coordinates = f.V3.Coordinates.Sub(center).MulScalar(scale)
+	}
+	if m.bvh != nil {
+		m.BuildBVH()
+	}
+}
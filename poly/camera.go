@@ -1,11 +1,17 @@
 package poly
 
-import . "github.com/arata-nvm/poly/vecmath"
+import (
+	"math"
+
+	. "github.com/arata-nvm/poly/vecmath"
+)
 
 type Camera struct {
 	Position Vector3
 	Target   Vector3
 	Up       Vector3
+
+	Projection Projection
 }
 
 func NewCamera(position, target, up Vector3) Camera {
@@ -15,3 +21,98 @@ func NewCamera(position, target, up Vector3) Camera {
 		Up:       up,
 	}
 }
+
+func (c Camera) ViewMatrix() Matrix4 {
+	return LookAt(c.Position, c.Target, c.Up)
+}
+
+// 正規化デバイス座標(-1~1)の点を通るワールド座標系の光線を返す
+// 始点はニアクリップ面上にあり、方向は正規化されている
+// 射影を持たないカメラでは方向がゼロになる
+func (c Camera) Unproject(x, y, aspect float64) Ray {
+	if c.Projection == nil {
+		return NewRay(c.Position, Zero())
+	}
+	return unproject(x, y, c.Projection.Matrix(aspect).Mul(c.ViewMatrix()))
+}
+
+func unproject(x, y float64, viewProjection Matrix4) Ray {
+	inv, ok := viewProjection.Inverse()
+	if !ok {
+		return NewRay(Zero(), Zero())
+	}
+	near := TransformCoordinate(NewVector3(x, y, -1), inv)
+	far := TransformCoordinate(NewVector3(x, y, 1), inv)
+	return NewRay(near, far.Sub(near).Normalize())
+}
+
+type Projection interface {
+	Matrix(aspect float64) Matrix4
+}
+
+type PerspectiveProjection struct {
+	Fov        float64
+	Horizontal bool
+	Near       float64
+	Far        float64
+}
+
+// fovyは度数法で指定する(Perspectiveと同じ)
+func NewPerspectiveProjection(fovy, near, far float64) *PerspectiveProjection {
+	return &PerspectiveProjection{
+		Fov:  fovy,
+		Near: near,
+		Far:  far,
+	}
+}
+
+func NewHorizontalPerspectiveProjection(fovx, near, far float64) *PerspectiveProjection {
+	return &PerspectiveProjection{
+		Fov:        fovx,
+		Horizontal: true,
+		Near:       near,
+		Far:        far,
+	}
+}
+
+func (p *PerspectiveProjection) Matrix(aspect float64) Matrix4 {
+	fovy := p.Fov
+	if p.Horizontal {
+		fovx := p.Fov * math.Pi / 180
+		fovy = 2 * math.Atan(math.Tan(fovx/2)/aspect) * 180 / math.Pi
+	}
+	return Perspective(fovy, aspect, p.Near, p.Far)
+}
+
+type OrthographicProjection struct {
+	Height float64
+	Near   float64
+	Far    float64
+}
+
+// heightは画面の縦方向に映る範囲の大きさ
+func NewOrthographicProjection(height, near, far float64) *OrthographicProjection {
+	return &OrthographicProjection{
+		Height: height,
+		Near:   near,
+		Far:    far,
+	}
+}
+
+func (p *OrthographicProjection) Matrix(aspect float64) Matrix4 {
+	top := p.Height / 2
+	right := top * aspect
+	return Orthographic(-right, right, -top, top, p.Near, p.Far)
+}
+
+type CustomProjection struct {
+	M Matrix4
+}
+
+func NewCustomProjection(m Matrix4) *CustomProjection {
+	return &CustomProjection{M: m}
+}
+
+func (p *CustomProjection) Matrix(_ float64) Matrix4 {
+	return p.M
+}
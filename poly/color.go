@@ -36,6 +36,14 @@ func (c Color) MulScalar(f float64) Color {
 	return Color{c.R * f, c.G * f, c.B * f, c.A}
 }
 
+func (c Color) Scale(f float64) Color {
+	return Color{c.R * f, c.G * f, c.B * f, c.A * f}
+}
+
+func (c1 Color) Lerp(c2 Color, t float64) Color {
+	return c1.Scale(1 - t).Add(c2.Scale(t))
+}
+
 func (c Color) Min(min Color) Color {
 	return Color{
 		math.Min(c.R, min.R),
@@ -46,9 +54,9 @@ func (c Color) Min(min Color) Color {
 }
 
 func (c Color) NRGBA() color.NRGBA {
-	cr := uint8(c.R * 255)
-	cg := uint8(c.G * 255)
-	cb := uint8(c.B * 255)
-	ca := uint8(c.A * 255)
+	cr := uint8(Clamp(c.R, 0, 1)*255 + 0.5)
+	cg := uint8(Clamp(c.G, 0, 1)*255 + 0.5)
+	cb := uint8(Clamp(c.B, 0, 1)*255 + 0.5)
+	ca := uint8(Clamp(c.A, 0, 1)*255 + 0.5)
 	return color.NRGBA{R: cr, G: cg, B: cb, A: ca}
 }
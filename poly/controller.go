@@ -0,0 +1,183 @@
+package poly
+
+import (
+	"math"
+
+	. "github.com/arata-nvm/poly/vecmath"
+)
+
+type OrbitController struct {
+	Target   Vector3
+	Yaw      float64
+	Pitch    float64
+	Distance float64
+
+	MinPitch    float64
+	MaxPitch    float64
+	MinDistance float64
+	MaxDistance float64
+}
+
+func NewOrbitController(target Vector3, distance float64) *OrbitController {
+	return &OrbitController{
+		Target:      target,
+		Distance:    distance,
+		MinPitch:    -math.Pi/2 + 0.01,
+		MaxPitch:    math.Pi/2 - 0.01,
+		MinDistance: 0.01,
+		MaxDistance: math.Inf(1),
+	}
+}
+
+func (o *OrbitController) Rotate(yaw, pitch float64) {
+	o.Yaw += yaw
+	o.Pitch = Clamp(o.Pitch+pitch, o.MinPitch, o.MaxPitch)
+}
+
+// factor < 1 で近づき、factor > 1 で遠ざかる
+func (o *OrbitController) Zoom(factor float64) {
+	o.Distance = Clamp(o.Distance*factor, o.MinDistance, o.MaxDistance)
+}
+
+// 画面の右方向と上方向に注視点を動かす
+func (o *OrbitController) Pan(right, up float64) {
+	forward := o.Target.Sub(o.Position()).Normalize()
+	r := forward.Cross(UnitY()).Normalize()
+	u := r.Cross(forward)
+	o.Target = o.Target.Add(r.MulScalar(right)).Add(u.MulScalar(up))
+}
+
+func (o *OrbitController) Position() Vector3 {
+	offset := NewVector3(
+		math.Cos(o.Pitch)*math.Sin(o.Yaw),
+		math.Sin(o.Pitch),
+		math.Cos(o.Pitch)*math.Cos(o.Yaw),
+	)
+	return o.Target.Add(offset.MulScalar(o.Distance))
+}
+
+func (o *OrbitController) Apply(c *Camera) {
+	c.Position = o.Position()
+	c.Target = o.Target
+	c.Up = UnitY()
+}
+
+type FlyController struct {
+	Position Vector3
+	Yaw      float64
+	Pitch    float64
+
+	MinPitch float64
+	MaxPitch float64
+}
+
+func NewFlyController(position Vector3) *FlyController {
+	return &FlyController{
+		Position: position,
+		MinPitch: -math.Pi/2 + 0.01,
+		MaxPitch: math.Pi/2 - 0.01,
+	}
+}
+
+// Yaw = Pitch = 0 のとき -Z 方向を向く
+func (f *FlyController) Forward() Vector3 {
+	return NewVector3(
+		-math.Cos(f.Pitch)*math.Sin(f.Yaw),
+		math.Sin(f.Pitch),
+		-math.Cos(f.Pitch)*math.Cos(f.Yaw),
+	)
+}
+
+func (f *FlyController) Right() Vector3 {
+	return f.Forward().Cross(UnitY()).Normalize()
+}
+
+func (f *FlyController) Turn(yaw, pitch float64) {
+	f.Yaw += yaw
+	f.Pitch = Clamp(f.Pitch+pitch, f.MinPitch, f.MaxPitch)
+}
+
+func (f *FlyController) Move(forward, right, up float64) {
+	f.Position = f.Position.
+		Add(f.Forward().MulScalar(forward)).
+		Add(f.Right().MulScalar(right)).
+		Add(UnitY().MulScalar(up))
+}
+
+func (f *FlyController) Apply(c *Camera) {
+	c.Position = f.Position
+	c.Target = f.Position.Add(f.Forward())
+	c.Up = UnitY()
+}
+
+// メッシュを囲む球の周りに等間隔に並んだn個のカメラを返す
+// elevationは水平面からの仰角(ラジアン)で、OrbitControllerと同じ範囲に制限する。fovyは度数法
+// 空のメッシュや1点に潰れたメッシュは半径1の球として扱う
+func Turntable(m *Mesh, n int, elevation, fovy float64) []Camera {
+	center, radius := frameSphere(m)
+	dist := fitDistance(radius, fovy)
+
+	cameras := make([]Camera, n)
+	o := NewOrbitController(center, dist)
+	o.Rotate(0, elevation)
+	for i := range cameras {
+		o.Yaw = 2 * math.Pi * float64(i) / float64(n)
+		c := NewCamera(Zero(), Zero(), UnitY())
+		o.Apply(&c)
+		c.Projection = NewPerspectiveProjection(fovy, math.Max(dist-radius, radius*0.01), dist+radius)
+		cameras[i] = c
+	}
+	return cameras
+}
+
+// カメラの向きを保ったまま、メッシュ全体が画面に収まるように動かす
+// 空のメッシュや1点に潰れたメッシュは半径1の球として扱う
+func FrameMesh(c *Camera, m *Mesh, aspect float64) {
+	center, radius := frameSphere(m)
+
+	dir := c.Position.Sub(c.Target)
+	if dir.LengthSq() == 0 {
+		dir = UnitZ()
+	}
+	dir = dir.Normalize()
+
+	switch p := c.Projection.(type) {
+	case *OrthographicProjection:
+		height := 2 * radius
+		if aspect < 1 {
+			height /= aspect
+		}
+		dist := 2 * radius
+		c.Projection = NewOrthographicProjection(height, dist-radius*1.01, dist+radius*1.01)
+		c.Position = center.Add(dir.MulScalar(dist))
+	default:
+		fovy := 45.0
+		if pp, ok := p.(*PerspectiveProjection); ok {
+			fovy = pp.Fov
+			if pp.Horizontal {
+				fovx := pp.Fov * math.Pi / 180
+				fovy = 2 * math.Atan(math.Tan(fovx/2)/aspect) * 180 / math.Pi
+			}
+		}
+
+		// 横方向の画角が狭い場合はそちらに合わせる
+		fovx := 2 * math.Atan(math.Tan(fovy*math.Pi/360)*aspect) * 180 / math.Pi
+		dist := fitDistance(radius, math.Min(fovy, fovx))
+		c.Position = center.Add(dir.MulScalar(dist))
+		c.Projection = NewPerspectiveProjection(fovy, math.Max(dist-radius, radius*0.01), dist+radius)
+	}
+	c.Target = center
+}
+
+// 半径0ではnearとfarが0になってしまうので、代わりに半径1を使う
+func frameSphere(m *Mesh) (Vector3, float64) {
+	s := m.WorldBoundingSphere()
+	if s.Radius <= 0 {
+		return s.Center, 1
+	}
+	return s.Center, s.Radius
+}
+
+func fitDistance(radius, fov float64) float64 {
+	return radius / math.Sin(fov*math.Pi/360)
+}
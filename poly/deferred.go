@@ -0,0 +1,194 @@
+package poly
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	. "github.com/arata-nvm/poly/vecmath"
+)
+
+type GBufferChannel int
+
+const (
+	// ワールド座標
+	GBufferPosition GBufferChannel = iota
+	GBufferNormal
+	GBufferAlbedo
+	GBufferMaterial
+	GBufferDepth
+)
+
+type GBuffer struct {
+	*Framebuffer
+}
+
+func NewGBuffer(width, height int) *GBuffer {
+	return &GBuffer{
+		Framebuffer: NewFramebuffer(width, height, 4),
+	}
+}
+
+func (g *GBuffer) Channel(c GBufferChannel) *ColorBuffer {
+	return g.ColorAttachments[c]
+}
+
+// 面が描かれた画素かどうかを返す
+// クリア色に左右されないよう、PostContextと同じく深度がクリア時の値のままかで判定する
+func (g *GBuffer) covered(i int) bool {
+	z := g.Depth.Pix[i]
+	return z != math.MaxFloat64 && z != -math.MaxFloat64
+}
+
+// 0~1に正規化する。幅が0の軸は0にする
+func normalizeRange(v, min, size float64) float64 {
+	if size <= 0 {
+		return 0
+	}
+	return (v - min) / size
+}
+
+// 各チャンネルを目視できるように0~1に正規化した画像を返す
+func (g *GBuffer) DebugImage(c GBufferChannel) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, g.Width, g.Height))
+	positions := g.Channel(GBufferPosition)
+
+	switch c {
+	case GBufferPosition:
+		min := NewVector3(math.MaxFloat64, math.MaxFloat64, math.MaxFloat64)
+		max := min.Negate()
+		for i, p := range positions.Pix {
+			if !g.covered(i) {
+				continue
+			}
+			min = NewVector3(math.Min(min.X, p.R), math.Min(min.Y, p.G), math.Min(min.Z, p.B))
+			max = NewVector3(math.Max(max.X, p.R), math.Max(max.Y, p.G), math.Max(max.Z, p.B))
+		}
+		size := max.Sub(min)
+		for i, p := range positions.Pix {
+			if !g.covered(i) {
+				continue
+			}
+			v := NewVector3(
+				normalizeRange(p.R, min.X, size.X),
+				normalizeRange(p.G, min.Y, size.Y),
+				normalizeRange(p.B, min.Z, size.Z),
+			)
+			img.SetNRGBA(i%g.Width, i/g.Width, NewColorFromVec(v).NRGBA())
+		}
+	case GBufferNormal:
+		for i, n := range g.Channel(GBufferNormal).Pix {
+			if !g.covered(i) {
+				continue
+			}
+			v := NewVector3(n.R, n.G, n.B).MulScalar(0.5).AddScalar(0.5)
+			img.SetNRGBA(i%g.Width, i/g.Width, NewColorFromVec(v).NRGBA())
+		}
+	case GBufferAlbedo, GBufferMaterial:
+		for i, a := range g.Channel(c).Pix {
+			if !g.covered(i) {
+				continue
+			}
+			a.A = 1
+			img.SetNRGBA(i%g.Width, i/g.Width, a.NRGBA())
+		}
+	case GBufferDepth:
+		min, max := math.MaxFloat64, -math.MaxFloat64
+		for i, z := range g.Depth.Pix {
+			if !g.covered(i) {
+				continue
+			}
+			min, max = math.Min(min, z), math.Max(max, z)
+		}
+		for i, z := range g.Depth.Pix {
+			if !g.covered(i) {
+				continue
+			}
+			f := normalizeRange(z, min, max-min)
+			img.SetNRGBA(i%g.Width, i/g.Width, NewColor(f, f, f, 1).NRGBA())
+		}
+	}
+
+	return img
+}
+
+type GBufferShader struct {
+	Color     Color
+	Texture   *Texture
+	Specular  float64
+	Shininess float64
+
+	model        Matrix4
+	normalMatrix Matrix3
+}
+
+func NewGBufferShader(color Color, specular, shininess float64) *GBufferShader {
+	return &GBufferShader{
+		Color:        color,
+		Specular:     specular,
+		Shininess:    shininess,
+		model:        Identity(),
+		normalMatrix: Identity3(),
+	}
+}
+
+func (s *GBufferShader) SetModel(m Matrix4) {
+	s.model = m
+	s.normalMatrix = m.NormalMatrix()
+}
+
+func (s *GBufferShader) Vertex(v Vertex, m Matrix4) Vertex {
+	v.World = s.model.MulVector(v.Coordinates)
+	v.Normal = s.normalMatrix.MulVector(v.Normal).Normalize()
+	v.Coordinates = TransformCoordinate(v.Coordinates, m)
+	return v
+}
+
+func (s *GBufferShader) albedo(v Vertex) Color {
+	if s.Texture != nil {
+		return s.Color.Mul(s.Texture.Map(v.Uv.X, v.Uv.Y))
+	}
+	return s.Color
+}
+
+func (s *GBufferShader) Fragment(v Vertex, _ Vector3) Color {
+	return s.albedo(v)
+}
+
+func (s *GBufferShader) FragmentTargets(v Vertex, _ Vector3, out []Color) {
+	n := v.Normal.Normalize()
+	out[GBufferPosition] = NewColor(v.World.X, v.World.Y, v.World.Z, 1)
+	out[GBufferNormal] = NewColor(n.X, n.Y, n.Z, 1)
+	out[GBufferAlbedo] = s.albedo(v)
+	out[GBufferMaterial] = NewColor(s.Specular, s.Shininess, 0, 1)
+}
+
+// Gバッファの各画素をライトごとに一度だけシェーディングし、現在のフレームバッファに書き込む
+// Gバッファの深度がクリア時の値のままの画素は背景とみなし、フレームバッファの色をそのまま残す
+// Gバッファとフレームバッファの大きさが異なるとpanicする
+func (d *Device) ShadeDeferred(g *GBuffer, lights []Light, ambient Color) {
+	dst := d.framebuffer.ColorAttachments[0]
+	if dst.Width != g.Width || dst.Height != g.Height {
+		panic(fmt.Sprintf("deferred: G-buffer is %dx%d but framebuffer is %dx%d", g.Width, g.Height, dst.Width, dst.Height))
+	}
+	eye := d.camera.Position
+	positions := g.Channel(GBufferPosition)
+	normals := g.Channel(GBufferNormal)
+	albedos := g.Channel(GBufferAlbedo)
+	materials := g.Channel(GBufferMaterial)
+
+	for i, pc := range positions.Pix {
+		if !g.covered(i) {
+			continue
+		}
+
+		p := NewVector3(pc.R, pc.G, pc.B)
+		n := NewVector3(normals.Pix[i].R, normals.Pix[i].G, normals.Pix[i].B)
+		albedo := albedos.Pix[i]
+		specular, shininess := materials.Pix[i].R, materials.Pix[i].G
+
+		c := albedo.Mul(ambient).Add(shadeBlinnPhong(p, n, eye, albedo, specular, shininess, lights))
+		c.A = albedo.A
+		dst.Pix[i] = c
+	}
+}
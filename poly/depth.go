@@ -0,0 +1,116 @@
+package poly
+
+import "math"
+
+type CompareFunc int
+
+const (
+	CompareNever CompareFunc = iota
+	CompareLess
+	CompareEqual
+	CompareLEqual
+	CompareGreater
+	CompareNotEqual
+	CompareGEqual
+	CompareAlways
+)
+
+func (f CompareFunc) Test(a, b float64) bool {
+	switch f {
+	case CompareNever:
+		return false
+	case CompareLess:
+		return a < b
+	case CompareEqual:
+		return a == b
+	case CompareLEqual:
+		return a <= b
+	case CompareGreater:
+		return a > b
+	case CompareNotEqual:
+		return a != b
+	case CompareGEqual:
+		return a >= b
+	default:
+		return true
+	}
+}
+
+// 比較の向きを反転する(reversed-Z用)
+func (f CompareFunc) reversed() CompareFunc {
+	switch f {
+	case CompareLess:
+		return CompareGreater
+	case CompareLEqual:
+		return CompareGEqual
+	case CompareGreater:
+		return CompareLess
+	case CompareGEqual:
+		return CompareLEqual
+	default:
+		return f
+	}
+}
+
+// 深度テストが無効なときは深度バッファに書き込まない
+func (d *Device) SetDepthTest(enabled bool) {
+	d.depthTest = enabled
+}
+
+func (d *Device) SetDepthFunc(f CompareFunc) {
+	d.depthFunc = f
+}
+
+func (d *Device) SetDepthMask(write bool) {
+	d.depthWrite = write
+}
+
+func (d *Device) SetDepthRange(near, far float64) {
+	d.depthNear = near
+	d.depthFar = far
+}
+
+// 深度の向きを反転する(近いほど大きな値になる)
+// 投影行列はそのままで、投影後のNDCの深度からウィンドウ深度への変換と比較の向きを入れ替えるだけの規約の切り替え
+// 深度はfloat64で保持するので、浮動小数点の深度バッファでreversed-Zを使ったときのような精度の向上はない
+// 深度の大小の向きが変わるので、深度バッファを新しい向きの最も遠い値でクリアする
+// それまでに書き込んだ深度は失われるので、フレームの描画を始める前に呼ぶ
+func (d *Device) SetReversedZ(enabled bool) {
+	d.reversedZ = enabled
+	d.ClearDepthBuffer(d.clearDepth())
+}
+
+func (d *Device) clearDepth() float64 {
+	if d.reversedZ {
+		return -math.MaxFloat64
+	}
+	return math.MaxFloat64
+}
+
+func (d *Device) windowDepth(z float64) float64 {
+	g := (z + 1) / 2
+	if d.reversedZ {
+		return d.depthFar - g*(d.depthFar-d.depthNear)
+	}
+	return d.depthNear + g*(d.depthFar-d.depthNear)
+}
+
+func (d *Device) ndcDepth(z float64) float64 {
+	g := (z - d.depthNear) / (d.depthFar - d.depthNear)
+	if d.reversedZ {
+		g = 1 - g
+	}
+	return g*2 - 1
+}
+
+func (d *Device) depthPass(z, stored float64) bool {
+	if !d.depthTest {
+		return true
+	}
+
+	f := d.depthFunc
+	if d.reversedZ {
+		f = f.reversed()
+	}
+	return f.Test(z, stored)
+}
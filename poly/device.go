@@ -10,23 +10,81 @@ type Device struct {
 	Width  int
 	Height int
 
-	camera      Camera
-	shader      Shader
-	colorBuffer *image.NRGBA
-	depthBuffer []float64
+	camera Camera
+	shader Shader
+
+	framebuffer        *Framebuffer
+	defaultFramebuffer *Framebuffer
+	fragmentOut        []Color
+
+	viewport    image.Rectangle
+	scissor     image.Rectangle
+	scissorTest bool
+
+	colorWrite bool
+
+	toneMapper ToneMapper
+	exposure   float64
+	srgb       bool
+	dither     Dither
+
+	depthTest  bool
+	depthFunc  CompareFunc
+	depthWrite bool
+	depthNear  float64
+	depthFar   float64
+	reversedZ  bool
+
+	stencilTest      bool
+	stencilFunc      CompareFunc
+	stencilRef       uint8
+	stencilMask      uint8
+	stencilWriteMask uint8
+	stencilFail      StencilOp
+	stencilDepthFail StencilOp
+	stencilPass      StencilOp
 
 	viewMatrix       Matrix4
 	projectionMatrix Matrix4
+	frustumCulling   bool
+
+	objectID uint32
+	faceID   uint32
+
+	pointSize  float64
+	pointShape PointShape
+	pointLight Vector3
 
 	cV1, cV2, cV3 Vertex
 }
 
 func NewDevice(width, height int) *Device {
+	fb := NewFramebuffer(width, height, 1)
 	d := &Device{
-		Width:       width,
-		Height:      height,
-		colorBuffer: image.NewNRGBA(image.Rect(0, 0, width, height)),
-		depthBuffer: make([]float64, width*height),
+		Width:  width,
+		Height: height,
+
+		framebuffer:        fb,
+		defaultFramebuffer: fb,
+		viewport:           image.Rect(0, 0, width, height),
+
+		colorWrite:     true,
+		frustumCulling: true,
+
+		exposure: 1,
+		srgb:     true,
+
+		depthTest:  true,
+		depthFunc:  CompareLEqual,
+		depthWrite: true,
+		depthNear:  0,
+		depthFar:   1,
+
+		stencilFunc:      CompareAlways,
+		stencilMask:      0xff,
+		stencilWriteMask: 0xff,
+
+		faceID: NoID,
 	}
 
 	d.ClearDepthBuffer(math.MaxFloat64)
@@ -35,30 +93,70 @@ func NewDevice(width, height int) *Device {
 }
 
 func (d *Device) ClearColorBuffer(c Color) {
-	for y := 0; y < d.Height; y++ {
-		for x := 0; x < d.Width; x++ {
-			d.colorBuffer.SetNRGBA(x, y, c.NRGBA())
+	for _, b := range d.framebuffer.ColorAttachments {
+		if !d.scissorTest {
+			b.Clear(c)
+			continue
 		}
+		d.forEachScissored(func(i int) {
+			b.Pix[i] = c
+		})
 	}
 }
 
 func (d *Device) ClearDepthBuffer(f float64) {
-	for i := range d.depthBuffer {
-		d.depthBuffer[i] = f
+	if !d.scissorTest {
+		d.framebuffer.Depth.Clear(f)
+		return
 	}
+	d.forEachScissored(func(i int) {
+		d.framebuffer.Depth.Pix[i] = f
+	})
 }
 
 func (d *Device) Image() image.Image {
-	return d.colorBuffer
+	return d.Resolve(d.defaultFramebuffer.ColorAttachments[0])
+}
+
+func (d *Device) ColorBuffer() *ColorBuffer {
+	return d.framebuffer.ColorAttachments[0]
 }
 
 func (d *Device) DepthBuffer() []float64 {
-	return d.depthBuffer
+	return d.framebuffer.Depth.Pix
+}
+
+func (d *Device) SetColorMask(write bool) {
+	d.colorWrite = write
 }
 
 func (d *Device) SetCamera(c Camera) {
 	d.camera = c
-	d.viewMatrix = LookAt(c.Position, c.Target, c.Up)
+	d.viewMatrix = c.ViewMatrix()
+	d.updateProjection()
+}
+
+func (d *Device) Camera() Camera {
+	return d.camera
+}
+
+func (d *Device) ViewMatrix() Matrix4 {
+	return d.viewMatrix
+}
+
+func (d *Device) ProjectionMatrix() Matrix4 {
+	return d.projectionMatrix
+}
+
+func (d *Device) Aspect() float64 {
+	return float64(d.viewport.Dx()) / float64(d.viewport.Dy())
+}
+
+// カメラが射影を持っていれば、描画先の縦横比に合わせて射影行列を作り直す
+func (d *Device) updateProjection() {
+	if d.camera.Projection != nil {
+		d.projectionMatrix = d.camera.Projection.Matrix(d.Aspect())
+	}
 }
 
 func (d *Device) SetShader(s Shader) {
@@ -70,22 +168,73 @@ func (d *Device) Perspective(fovy, aspect, near, far float64) {
 }
 
 func (d *Device) putPixel(x, y int, z float64, c Color) {
-	if x < 0 || y < 0 || x >= d.Width || y >= d.Height {
+	index, ok := d.testPixel(x, y, z)
+	if !ok {
+		return
+	}
+
+	d.writeIDs(index)
+	if !d.colorWrite {
+		return
+	}
+
+	for _, b := range d.framebuffer.ColorAttachments {
+		b.Pix[index] = c
+	}
+}
+
+// ステンシルテストと深度テストを行い、通過すればバッファ上の位置を返す
+func (d *Device) testPixel(x, y int, z float64) (int, bool) {
+	fb := d.framebuffer
+	if !image.Pt(x, y).In(d.drawableRect()) {
+		return 0, false
+	}
+
+	y = fb.Height - y - 1
+	index := x + y*fb.Width
+	if !d.stencilPassed(index) {
+		d.updateStencil(index, d.stencilFail)
+		return 0, false
+	}
+
+	if !d.depthPass(z, fb.Depth.Pix[index]) {
+		d.updateStencil(index, d.stencilDepthFail)
+		return 0, false
+	}
+
+	d.updateStencil(index, d.stencilPass)
+	// OpenGLと同じく、深度テストが無効なときは深度バッファに書き込まない
+	if d.depthTest && d.depthWrite {
+		fb.Depth.Pix[index] = z
+	}
+	return index, true
+}
+
+func (d *Device) shadeFragment(index int, v Vertex, w Vector3) {
+	if !d.colorWrite {
 		return
 	}
 
-	y = d.Height - y - 1
-	index := x + y*d.Width
-	if d.depthBuffer[index] < z {
+	attachments := d.framebuffer.ColorAttachments
+	if s, ok := d.shader.(MultiTargetShader); ok && len(attachments) > 1 {
+		if len(d.fragmentOut) < len(attachments) {
+			d.fragmentOut = make([]Color, len(attachments))
+		}
+		out := d.fragmentOut[:len(attachments)]
+		s.FragmentTargets(v, w, out)
+		for i, b := range attachments {
+			b.Pix[index] = out[i]
+		}
 		return
 	}
 
-	d.depthBuffer[index] = z
-	d.colorBuffer.Set(x, y, c.NRGBA())
+	if len(attachments) > 0 {
+		attachments[0].Pix[index] = d.shader.Fragment(v, w)
+	}
 }
 
 func (d *Device) DrawPoint(v Vector3, c Color) {
-	d.putPixel(int(v.X), int(v.Y), v.Y, c)
+	d.putPixel(int(v.X), int(v.Y), v.Z, c)
 }
 
 // TODO v1 > v2
@@ -136,31 +285,75 @@ func (d *Device) DrawLine(v1, v2 Vector3, c Color) {
 }
 
 func (d *Device) DrawMesh(mesh *Mesh) {
-	tm := Translate(mesh.Position)
-	rm := RotateX(mesh.Rotation.X).Mul(RotateY(mesh.Rotation.Y)).Mul(RotateZ(mesh.Rotation.Z))
-	sm := Scale(mesh.Scale)
-	modelMatrix := tm.Mul(rm).Mul(sm)
+	d.drawMesh(mesh, mesh.ModelMatrix())
+}
+
+func (d *Device) drawMesh(mesh *Mesh, modelMatrix Matrix4) {
 	transformMatrix := d.projectionMatrix.Mul(d.viewMatrix).Mul(modelMatrix)
+	if s, ok := d.shader.(ModelShader); ok {
+		s.SetModel(modelMatrix)
+	}
+
+	defer func() { d.faceID = NoID }()
+
+	if !d.frustumCulling {
+		for i := range mesh.Faces {
+			d.drawFace(mesh, i, transformMatrix)
+		}
+		return
+	}
 
-	for _, f := range mesh.Faces {
-		d.cV1 = d.transformVertex(f.V1, transformMatrix)
-		d.cV2 = d.transformVertex(f.V2, transformMatrix)
-		d.cV3 = d.transformVertex(f.V3, transformMatrix)
-		d.DrawTriangle(d.cV1.Coordinates, d.cV2.Coordinates, d.cV3.Coordinates)
+	// モデル座標系の視錐台で判定するので、箱を変換しなくてよい
+	frustum := FrustumFromMatrix(transformMatrix)
+	if mesh.bvh == nil {
+		if frustum.IntersectsAABB(mesh.BoundingBox()) {
+			for i := range mesh.Faces {
+				d.drawFace(mesh, i, transformMatrix)
+			}
+		}
+		return
 	}
+
+	mesh.bvh.Query(frustum.IntersectsAABB, func(i int) {
+		d.drawFace(mesh, i, transformMatrix)
+	})
 }
 
-func (d *Device) transformVertex(v Vertex, m Matrix4) Vertex {
-	cx, cy := d.Width/2, d.Height/2
-	scale := float64(d.Width) / 2
+func (d *Device) drawFace(mesh *Mesh, i int, transformMatrix Matrix4) {
+	f := mesh.Faces[i]
+	d.faceID = uint32(i)
+	d.cV1 = d.transformVertex(f.V1, transformMatrix)
+	d.cV2 = d.transformVertex(f.V2, transformMatrix)
+	d.cV3 = d.transformVertex(f.V3, transformMatrix)
+	d.DrawTriangle(d.cV1.Coordinates, d.cV2.Coordinates, d.cV3.Coordinates)
+}
 
+func (d *Device) SetFrustumCulling(enabled bool) {
+	d.frustumCulling = enabled
+}
+
+func (d *Device) transformVertex(v Vertex, m Matrix4) Vertex {
 	v = d.shader.Vertex(v, m)
-	v.Coordinates.X = v.Coordinates.X*scale + float64(cx)
-	v.Coordinates.Y = v.Coordinates.Y*scale + float64(cy)
+	v.Coordinates.X, v.Coordinates.Y = d.ndcToScreen(v.Coordinates.X, v.Coordinates.Y)
+	v.Coordinates.Z = d.windowDepth(v.Coordinates.Z)
 
 	return v
 }
 
+func (d *Device) ndcToScreen(x, y float64) (float64, float64) {
+	vp := d.viewport
+	sx := float64(vp.Min.X) + (x+1)/2*float64(vp.Dx())
+	sy := float64(vp.Min.Y) + (y+1)/2*float64(vp.Dy())
+	return sx, sy
+}
+
+func (d *Device) screenToNDC(x, y float64) (float64, float64) {
+	vp := d.viewport
+	nx := (x-float64(vp.Min.X))/float64(vp.Dx())*2 - 1
+	ny := (y-float64(vp.Min.Y))/float64(vp.Dy())*2 - 1
+	return nx, ny
+}
+
 func (d *Device) DrawWiredTriangle(v1, v2, v3 Vector3, c Color) {
 	d.DrawLine(v1, v2, c)
 	d.DrawLine(v2, v3, c)
@@ -224,14 +417,19 @@ func (d *Device) scanLine(y int, va, vb, vc, vd Vector3) {
 		}
 		z := Interpolate(z1, z2, g)
 
+		index, ok := d.testPixel(x, y, z)
+		if !ok {
+			continue
+		}
+
 		w1 := ((v2.Y-v3.Y)*(float64(x)-v3.X) + (v3.X-v2.X)*(float64(y)-v3.Y)) / ((v2.Y-v3.Y)*(v1.X-v3.X) + (v3.X-v2.X)*(v1.Y-v3.Y))
 		w2 := ((v3.Y-v1.Y)*(float64(x)-v3.X) + (v1.X-v3.X)*(float64(y)-v3.Y)) / ((v2.Y-v3.Y)*(v1.X-v3.X) + (v3.X-v2.X)*(v1.Y-v3.Y))
 		w3 := 1 - w1 - w2
 		w := NewVector3(w1, w2, w3)
 		v := InterpolateVertex(d.cV1, d.cV2, d.cV3, w)
 
-		c := d.shader.Fragment(v, w)
-		d.putPixel(x, y, z, c)
+		d.writeIDs(index)
+		d.shadeFragment(index, v, w)
 	}
 }
 
@@ -1,7 +1,15 @@
 package poly
 
+import . "github.com/arata-nvm/poly/vecmath"
+
+// OBJの "s off" に当たるスムージンググループ。法線を隣の面と共有しない
+const SmoothingOff = -1
+
 type Face struct {
 	V1, V2, V3 Vertex
+
+	// 同じグループの面の間でだけ法線を滑らかにつなぐ(0はグループ指定なし)
+	SmoothingGroup int
 }
 
 func (f *Face) CalcNormal() {
@@ -13,3 +21,15 @@ func (f *Face) CalcNormal() {
 	f.V2.Normal = n
 	f.V3.Normal = n
 }
+
+func (f *Face) Area() float64 {
+	d1 := f.V2.Coordinates.Sub(f.V1.Coordinates)
+	d2 := f.V3.Coordinates.Sub(f.V1.Coordinates)
+	return d1.Cross(d2).Length() / 2
+}
+
+func (f *Face) Normal() Vector3 {
+	d1 := f.V2.Coordinates.Sub(f.V1.Coordinates)
+	d2 := f.V3.Coordinates.Sub(f.V1.Coordinates)
+	return d1.Cross(d2).Normalize()
+}
@@ -0,0 +1,108 @@
+package poly
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	. "github.com/arata-nvm/poly/vecmath"
+)
+
+type DepthBuffer struct {
+	Width  int
+	Height int
+
+	Pix []float64
+}
+
+func NewDepthBuffer(width, height int) *DepthBuffer {
+	return &DepthBuffer{
+		Width:  width,
+		Height: height,
+		Pix:    make([]float64, width*height),
+	}
+}
+
+func (b *DepthBuffer) Clear(f float64) {
+	for i := range b.Pix {
+		b.Pix[i] = f
+	}
+}
+
+func (b *DepthBuffer) Get(x, y int) float64 {
+	if x < 0 || y < 0 || x >= b.Width || y >= b.Height {
+		return 0
+	}
+	return b.Pix[x+y*b.Width]
+}
+
+func (b *DepthBuffer) ColorModel() color.Model {
+	return color.Gray16Model
+}
+
+func (b *DepthBuffer) Bounds() image.Rectangle {
+	return image.Rect(0, 0, b.Width, b.Height)
+}
+
+func (b *DepthBuffer) At(x, y int) color.Color {
+	z := Clamp(b.Get(x, y), 0, 1)
+	return color.Gray16{Y: uint16(z*0xffff + 0.5)}
+}
+
+type Framebuffer struct {
+	Width  int
+	Height int
+
+	ColorAttachments []*ColorBuffer
+	Depth            *DepthBuffer
+	Stencil          []uint8
+
+	// EnableIDBuffersを呼ぶまではnil
+	ObjectIDs *IDBuffer
+	FaceIDs   *IDBuffer
+}
+
+func NewFramebuffer(width, height, colorAttachments int) *Framebuffer {
+	fb := &Framebuffer{
+		Width:            width,
+		Height:           height,
+		ColorAttachments: make([]*ColorBuffer, colorAttachments),
+		Depth:            NewDepthBuffer(width, height),
+		Stencil:          make([]uint8, width*height),
+	}
+
+	for i := range fb.ColorAttachments {
+		fb.ColorAttachments[i] = NewColorBuffer(width, height)
+	}
+	fb.Depth.Clear(math.MaxFloat64)
+
+	return fb
+}
+
+func (fb *Framebuffer) ColorTexture(i int) *Texture {
+	return &Texture{
+		Width:  fb.Width,
+		Height: fb.Height,
+		Image:  fb.ColorAttachments[i],
+	}
+}
+
+func (fb *Framebuffer) DepthTexture() *Texture {
+	return &Texture{
+		Width:  fb.Width,
+		Height: fb.Height,
+		Image:  fb.Depth,
+	}
+}
+
+func (d *Device) SetFramebuffer(fb *Framebuffer) {
+	if fb == nil {
+		fb = d.defaultFramebuffer
+	}
+	d.framebuffer = fb
+	d.ResetViewport()
+}
+
+func (d *Device) Framebuffer() *Framebuffer {
+	return d.framebuffer
+}
@@ -0,0 +1,84 @@
+package poly
+
+import (
+	"image"
+	"image/color"
+
+	. "github.com/arata-nvm/poly/vecmath"
+)
+
+type ColorBuffer struct {
+	Width  int
+	Height int
+
+	Pix []Color
+}
+
+func NewColorBuffer(width, height int) *ColorBuffer {
+	return &ColorBuffer{
+		Width:  width,
+		Height: height,
+		Pix:    make([]Color, width*height),
+	}
+}
+
+func (b *ColorBuffer) Clear(c Color) {
+	for i := range b.Pix {
+		b.Pix[i] = c
+	}
+}
+
+func (b *ColorBuffer) Get(x, y int) Color {
+	if x < 0 || y < 0 || x >= b.Width || y >= b.Height {
+		return Color{}
+	}
+	return b.Pix[x+y*b.Width]
+}
+
+func (b *ColorBuffer) Set(x, y int, c Color) {
+	if x < 0 || y < 0 || x >= b.Width || y >= b.Height {
+		return
+	}
+	b.Pix[x+y*b.Width] = c
+}
+
+// 端の画素を延長してバイリニア補間する
+func (b *ColorBuffer) Sample(x, y float64) Color {
+	x = Clamp(x-0.5, 0, float64(b.Width-1))
+	y = Clamp(y-0.5, 0, float64(b.Height-1))
+	x0, y0 := int(x), int(y)
+	x1, y1 := Min(x0+1, b.Width-1), Min(y0+1, b.Height-1)
+	fx, fy := x-float64(x0), y-float64(y0)
+
+	c00 := b.Pix[x0+y0*b.Width]
+	c10 := b.Pix[x1+y0*b.Width]
+	c01 := b.Pix[x0+y1*b.Width]
+	c11 := b.Pix[x1+y1*b.Width]
+	return c00.Lerp(c10, fx).Lerp(c01.Lerp(c11, fx), fy)
+}
+
+func (b *ColorBuffer) Copy() *ColorBuffer {
+	c := NewColorBuffer(b.Width, b.Height)
+	copy(c.Pix, b.Pix)
+	return c
+}
+
+func (b *ColorBuffer) ColorModel() color.Model {
+	return color.NRGBA64Model
+}
+
+func (b *ColorBuffer) Bounds() image.Rectangle {
+	return image.Rect(0, 0, b.Width, b.Height)
+}
+
+// 線形な値をそのまま16bitに丸めて返す
+func (b *ColorBuffer) At(x, y int) color.Color {
+	c := b.Get(x, y)
+	f := float64(0xffff)
+	return color.NRGBA64{
+		R: uint16(Clamp(c.R, 0, 1)*f + 0.5),
+		G: uint16(Clamp(c.G, 0, 1)*f + 0.5),
+		B: uint16(Clamp(c.B, 0, 1)*f + 0.5),
+		A: uint16(Clamp(c.A, 0, 1)*f + 0.5),
+	}
+}
@@ -0,0 +1,334 @@
+package poly
+
+import (
+	"math"
+	"sort"
+
+	. "github.com/arata-nvm/poly/vecmath"
+)
+
+// メッシュの頂点の凸包。モデル座標系で計算する
+func (m *Mesh) ConvexHull() *Mesh {
+	var points []Vector3
+	for _, f := range m.Faces {
+		points = append(points, f.V1.Coordinates, f.V2.Coordinates, f.V3.Coordinates)
+	}
+	return ConvexHull(points)
+}
+
+// QuickHullで点群の凸包を求める。面は外向きで、法線は面ごとに平ら
+// 誤差の範囲で面の上にある点や重複した点は凸包の頂点にしない
+// すべての点が同一平面上にある場合は両面の多角形、同一直線上にある場合は空のメッシュを返す
+func ConvexHull(points []Vector3) *Mesh {
+	m := NewMesh()
+	h := newQuickHull(points)
+	if h == nil {
+		return m
+	}
+
+	for _, f := range h.faces {
+		if f.deleted {
+			continue
+		}
+		face := &Face{
+			V1: Vertex{Coordinates: h.points[f.v[0]]},
+			V2: Vertex{Coordinates: h.points[f.v[1]]},
+			V3: Vertex{Coordinates: h.points[f.v[2]]},
+		}
+		face.CalcNormal()
+		m.Faces = append(m.Faces, face)
+	}
+	return m
+}
+
+type hullFace struct {
+	v       [3]int
+	normal  Vector3
+	offset  float64
+	outside []int
+	deleted bool
+}
+
+func (f *hullFace) distance(p Vector3) float64 {
+	return f.normal.Dot(p) - f.offset
+}
+
+type quickHull struct {
+	points []Vector3
+	faces  []*hullFace
+	// 有向辺(a, b)を持つ面
+	edges map[[2]int]int
+	eps   float64
+	// 外側の点を持つ面。削除済みの面や、点を持たなくなった面も残っていることがある
+	pending []int
+}
+
+// 点が同一平面上にあればその場で平らな凸包を作り、同一直線上ならnilを返す
+func newQuickHull(input []Vector3) *quickHull {
+	h := &quickHull{edges: make(map[[2]int]int)}
+	seen := make(map[Vector3]bool)
+	bounds := EmptyAABB()
+	for _, p := range input {
+		if seen[p] || math.IsNaN(p.X) || math.IsNaN(p.Y) || math.IsNaN(p.Z) {
+			continue
+		}
+		seen[p] = true
+		h.points = append(h.points, p)
+		bounds = bounds.Extend(p)
+	}
+	if len(h.points) == 0 {
+		return nil
+	}
+
+	// 点群の大きさに対する許容誤差と、座標の絶対値による丸め誤差の大きい方
+	abs := func(v Vector3) float64 {
+		return math.Abs(v.X) + math.Abs(v.Y) + math.Abs(v.Z)
+	}
+	h.eps = math.Max(1e-9*bounds.Size().Length(), 1e-15*math.Max(abs(bounds.Min), abs(bounds.Max)))
+
+	simplex, ok := h.initialSimplex()
+	if !ok {
+		return nil
+	}
+	if len(simplex) == 3 {
+		h.planar(simplex)
+		return h
+	}
+
+	a, b, c, d := simplex[0], simplex[1], simplex[2], simplex[3]
+	h.addFace(a, b, c)
+	h.addFace(a, c, d)
+	h.addFace(a, d, b)
+	h.addFace(b, d, c)
+	// 4点目が1つ目の面の表側にあれば、すべての面を裏返して作り直す
+	if h.faces[0].distance(h.points[d]) > 0 {
+		h.faces, h.edges = nil, make(map[[2]int]int)
+		h.addFace(a, c, b)
+		h.addFace(a, d, c)
+		h.addFace(a, b, d)
+		h.addFace(b, c, d)
+	}
+
+	var rest []int
+	for i := range h.points {
+		if i != a && i != b && i != c && i != d {
+			rest = append(rest, i)
+		}
+	}
+	h.assign(rest, []int{0, 1, 2, 3})
+
+	for len(h.pending) > 0 {
+		fi := h.pending[len(h.pending)-1]
+		h.pending = h.pending[:len(h.pending)-1]
+		if f := h.faces[fi]; !f.deleted && len(f.outside) > 0 {
+			h.expand(fi)
+		}
+	}
+	return h
+}
+
+// 最も離れた2点、その直線から最も離れた点、その平面から最も離れた点を選ぶ
+func (h *quickHull) initialSimplex() ([]int, bool) {
+	if len(h.points) < 3 {
+		return nil, false
+	}
+
+	var extremes []int
+	for axis := 0; axis < 3; axis++ {
+		lo, hi := 0, 0
+		for i, p := range h.points {
+			if axisValue(p, axis) < axisValue(h.points[lo], axis) {
+				lo = i
+			}
+			if axisValue(p, axis) > axisValue(h.points[hi], axis) {
+				hi = i
+			}
+		}
+		extremes = append(extremes, lo, hi)
+	}
+
+	a, b, best := 0, 0, -1.0
+	for _, i := range extremes {
+		for _, j := range extremes {
+			if d := h.points[i].Sub(h.points[j]).LengthSq(); d > best {
+				a, b, best = i, j, d
+			}
+		}
+	}
+
+	pa := h.points[a]
+	dir := h.points[b].Sub(pa).Normalize()
+	c, best := -1, h.eps
+	for i, p := range h.points {
+		q := p.Sub(pa)
+		if d := q.Sub(dir.MulScalar(q.Dot(dir))).Length(); d > best {
+			c, best = i, d
+		}
+	}
+	if c < 0 {
+		return nil, false
+	}
+
+	n := h.points[b].Sub(pa).Cross(h.points[c].Sub(pa)).Normalize()
+	d, best := -1, h.eps
+	for i, p := range h.points {
+		if dist := math.Abs(p.Sub(pa).Dot(n)); dist > best {
+			d, best = i, dist
+		}
+	}
+	if d < 0 {
+		return []int{a, b, c}, true
+	}
+	return []int{a, b, c, d}, true
+}
+
+func axisValue(v Vector3, axis int) float64 {
+	switch axis {
+	case 0:
+		return v.X
+	case 1:
+		return v.Y
+	}
+	return v.Z
+}
+
+func (h *quickHull) addFace(a, b, c int) int {
+	pa, pb, pc := h.points[a], h.points[b], h.points[c]
+	n := pb.Sub(pa).Cross(pc.Sub(pa)).Normalize()
+	f := &hullFace{v: [3]int{a, b, c}, normal: n, offset: n.Dot(pa)}
+
+	i := len(h.faces)
+	h.faces = append(h.faces, f)
+	h.edges[[2]int{a, b}] = i
+	h.edges[[2]int{b, c}] = i
+	h.edges[[2]int{c, a}] = i
+	return i
+}
+
+// 各点を、誤差より遠く表側にある最初の面に割り当てる。どの面の外側でもない点は捨てる
+func (h *quickHull) assign(points, faces []int) {
+	for _, p := range points {
+		for _, fi := range faces {
+			if f := h.faces[fi]; f.distance(h.points[p]) > h.eps {
+				if len(f.outside) == 0 {
+					h.pending = append(h.pending, fi)
+				}
+				f.outside = append(f.outside, p)
+				break
+			}
+		}
+	}
+}
+
+// 面fiの外側で最も遠い点を凸包に加える
+func (h *quickHull) expand(fi int) {
+	f := h.faces[fi]
+	eye, best := -1, -1.0
+	for _, p := range f.outside {
+		if d := f.distance(h.points[p]); d > best {
+			eye, best = p, d
+		}
+	}
+	pe := h.points[eye]
+
+	// 点から見える面を隣へたどって集め、見えない面との境界の辺を地平線とする
+	visible := map[int]bool{fi: true}
+	stack := []int{fi}
+	var horizon [][2]int
+	for len(stack) > 0 {
+		g := h.faces[stack[len(stack)-1]]
+		stack = stack[:len(stack)-1]
+		for k := 0; k < 3; k++ {
+			a, b := g.v[k], g.v[(k+1)%3]
+			ni, ok := h.edges[[2]int{b, a}]
+			if !ok {
+				continue
+			}
+			if visible[ni] {
+				continue
+			}
+			if h.faces[ni].distance(pe) > h.eps {
+				visible[ni] = true
+				stack = append(stack, ni)
+				continue
+			}
+			horizon = append(horizon, [2]int{a, b})
+		}
+	}
+
+	var orphans []int
+	for vi := range visible {
+		g := h.faces[vi]
+		g.deleted = true
+		for k := 0; k < 3; k++ {
+			key := [2]int{g.v[k], g.v[(k+1)%3]}
+			if h.edges[key] == vi {
+				delete(h.edges, key)
+			}
+		}
+		for _, p := range g.outside {
+			if p != eye {
+				orphans = append(orphans, p)
+			}
+		}
+		g.outside = nil
+	}
+
+	var created []int
+	for _, e := range horizon {
+		created = append(created, h.addFace(e[0], e[1], eye))
+	}
+	h.assign(orphans, created)
+}
+
+// 同一平面上の点の凸包を、表と裏の2枚の多角形として作る
+func (h *quickHull) planar(simplex []int) {
+	pa := h.points[simplex[0]]
+	n := h.points[simplex[1]].Sub(pa).Cross(h.points[simplex[2]].Sub(pa)).Normalize()
+	u, v := planarBasis(n)
+
+	// Andrewの単調連鎖法
+	order := make([]int, len(h.points))
+	for i := range order {
+		order[i] = i
+	}
+	key := func(i int) (float64, float64) {
+		p := h.points[i].Sub(pa)
+		return p.Dot(u), p.Dot(v)
+	}
+	sort.Slice(order, func(i, j int) bool {
+		xi, yi := key(order[i])
+		xj, yj := key(order[j])
+		if xi != xj {
+			return xi < xj
+		}
+		return yi < yj
+	})
+	cross := func(o, a, b int) float64 {
+		ox, oy := key(o)
+		ax, ay := key(a)
+		bx, by := key(b)
+		return (ax-ox)*(by-oy) - (ay-oy)*(bx-ox)
+	}
+
+	var hull []int
+	for pass := 0; pass < 2; pass++ {
+		start := len(hull)
+		for _, i := range order {
+			for len(hull) >= start+2 && cross(hull[len(hull)-2], hull[len(hull)-1], i) <= 0 {
+				hull = hull[:len(hull)-1]
+			}
+			hull = append(hull, i)
+		}
+		hull = hull[:len(hull)-1]
+		for l, r := 0, len(order)-1; l < r; l, r = l+1, r-1 {
+			order[l], order[r] = order[r], order[l]
+		}
+	}
+
+	// 外周は(u, v)平面上で反時計回りなので、法線はu×v方向を向く
+	for i := 1; i+1 < len(hull); i++ {
+		h.addFace(hull[0], hull[i], hull[i+1])
+		h.addFace(hull[0], hull[i+1], hull[i])
+	}
+}
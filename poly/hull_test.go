@@ -0,0 +1,118 @@
+package poly
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	. "github.com/arata-nvm/poly/vecmath"
+)
+
+func cubeHullInput() []Vector3 {
+	var ps []Vector3
+	for _, x := range []float64{-1, 1} {
+		for _, y := range []float64{-1, 1} {
+			for _, z := range []float64{-1, 1} {
+				// 重複した角
+				ps = append(ps, NewVector3(x, y, z), NewVector3(x, y, z))
+			}
+		}
+	}
+	// 面の中心と辺の中点は面の上にあるので凸包の頂点にならない
+	for _, a := range []float64{-1, 1} {
+		ps = append(ps, NewVector3(a, 0, 0), NewVector3(0, a, 0), NewVector3(0, 0, a))
+		ps = append(ps, NewVector3(a, a, 0), NewVector3(0, a, a), NewVector3(a, 0, a))
+	}
+	// 内側の点
+	ps = append(ps, Zero(), NewVector3(0.5, -0.2, 0.1))
+	return ps
+}
+
+func TestConvexHull(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	var sphere []Vector3
+	for i := 0; i < 500; i++ {
+		v := NewVector3(r.NormFloat64(), r.NormFloat64(), r.NormFloat64()).Normalize()
+		sphere = append(sphere, v.MulScalar(1+r.Float64()*0.1))
+	}
+
+	cases := []struct {
+		name   string
+		points []Vector3
+		// -1なら面の数を、0なら体積を確かめない
+		faces  int
+		volume float64
+	}{
+		{"cube", cubeHullInput(), 12, 8},
+		{"tetrahedron", []Vector3{Zero(), UnitX(), UnitY(), UnitZ()}, 4, 1.0 / 6},
+		{"sphere", sphere, -1, 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := ConvexHull(c.points)
+			if c.faces >= 0 && len(m.Faces) != c.faces {
+				t.Fatalf("got %d faces, want %d", len(m.Faces), c.faces)
+			}
+			if r := m.Validate(); !r.OK() {
+				t.Fatalf("hull is not closed and consistently wound: %+v", r)
+			}
+			if c.volume > 0 && !ApproxEqual(m.Volume(), c.volume, 1e-9) {
+				t.Errorf("volume = %v, want %v", m.Volume(), c.volume)
+			}
+
+			// すべての点が各面の裏側にある(面が外向き)
+			for i, f := range m.Faces {
+				n := f.Normal()
+				for _, p := range c.points {
+					if d := p.Sub(f.V1.Coordinates).Dot(n); d > 1e-9 {
+						t.Fatalf("face %d: point %v is %v in front", i, p, d)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestConvexHullDegenerate(t *testing.T) {
+	var grid []Vector3
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 4; y++ {
+			grid = append(grid, NewVector3(float64(x), float64(y), 0))
+		}
+	}
+
+	cases := []struct {
+		name   string
+		points []Vector3
+		faces  int
+	}{
+		{"empty", nil, 0},
+		{"single", []Vector3{UnitX()}, 0},
+		{"duplicates", []Vector3{UnitX(), UnitX(), UnitX()}, 0},
+		{"collinear", []Vector3{Zero(), UnitX(), NewVector3(2, 0, 0), NewVector3(0.5, 0, 0)}, 0},
+		{"NaN", []Vector3{NewVector3(math.NaN(), 0, 0), Zero(), UnitX()}, 0},
+		// 4つの角を持つ正方形を表と裏の2枚ずつで表す
+		{"coplanar", grid, 4},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := ConvexHull(c.points)
+			if len(m.Faces) != c.faces {
+				t.Fatalf("got %d faces, want %d", len(m.Faces), c.faces)
+			}
+			for i, f := range m.Faces {
+				if f.IsDegenerate() {
+					t.Errorf("face %d is degenerate", i)
+				}
+				if n := f.Normal(); math.Abs(math.Abs(n.Z)-1) > 1e-9 {
+					t.Errorf("face %d normal %v is not perpendicular to the plane", i, n)
+				}
+			}
+			if c.faces > 0 && !ApproxEqual(m.SurfaceArea(), 2*9, 1e-9) {
+				t.Errorf("area = %v, want both sides of a 3x3 square", m.SurfaceArea())
+			}
+		})
+	}
+}
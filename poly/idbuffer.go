@@ -0,0 +1,135 @@
+package poly
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+	"io"
+	"math"
+)
+
+// 何も描かれていない画素のID
+const NoID uint32 = math.MaxUint32
+
+type IDBuffer struct {
+	Width  int
+	Height int
+
+	Pix []uint32
+}
+
+func NewIDBuffer(width, height int) *IDBuffer {
+	b := &IDBuffer{
+		Width:  width,
+		Height: height,
+		Pix:    make([]uint32, width*height),
+	}
+	b.Clear(NoID)
+	return b
+}
+
+func (b *IDBuffer) Clear(id uint32) {
+	for i := range b.Pix {
+		b.Pix[i] = id
+	}
+}
+
+func (b *IDBuffer) Get(x, y int) uint32 {
+	if x < 0 || y < 0 || x >= b.Width || y >= b.Height {
+		return NoID
+	}
+	return b.Pix[x+y*b.Width]
+}
+
+// 左上の画素から順に、リトルエンディアンのuint32として書き出す
+func (b *IDBuffer) WriteRaw(w io.Writer) error {
+	return binary.Write(w, binary.LittleEndian, b.Pix)
+}
+
+// 画像として扱うと、IDごとに異なる色で塗り分けられる(NoIDは黒)
+func (b *IDBuffer) ColorModel() color.Model {
+	return color.RGBAModel
+}
+
+func (b *IDBuffer) Bounds() image.Rectangle {
+	return image.Rect(0, 0, b.Width, b.Height)
+}
+
+func (b *IDBuffer) At(x, y int) color.Color {
+	return IDColor(b.Get(x, y))
+}
+
+// 隣り合うIDでも見分けやすいように、ハッシュ値から色を決める
+func IDColor(id uint32) color.RGBA {
+	if id == NoID {
+		return color.RGBA{A: 0xff}
+	}
+
+	h := id + 1
+	h ^= h >> 16
+	h *= 0x7feb352d
+	h ^= h >> 15
+	h *= 0x846ca68b
+	h ^= h >> 16
+
+	// 暗すぎる色は背景と紛らわしいので、各チャンネルの下限を上げる
+	return color.RGBA{
+		R: uint8(h) | 0x40,
+		G: uint8(h>>8) | 0x40,
+		B: uint8(h>>16) | 0x40,
+		A: 0xff,
+	}
+}
+
+// 現在のフレームバッファにオブジェクトIDと面IDのバッファを用意する
+// 以降、深度テストを通過した画素には色と同時にIDが書き込まれる
+func (fb *Framebuffer) EnableIDBuffers() {
+	if fb.ObjectIDs == nil {
+		fb.ObjectIDs = NewIDBuffer(fb.Width, fb.Height)
+	}
+	if fb.FaceIDs == nil {
+		fb.FaceIDs = NewIDBuffer(fb.Width, fb.Height)
+	}
+}
+
+func (d *Device) EnableIDBuffers() {
+	d.framebuffer.EnableIDBuffers()
+}
+
+func (d *Device) ObjectIDBuffer() *IDBuffer {
+	return d.framebuffer.ObjectIDs
+}
+
+func (d *Device) FaceIDBuffer() *IDBuffer {
+	return d.framebuffer.FaceIDs
+}
+
+func (d *Device) ClearIDBuffers() {
+	for _, b := range []*IDBuffer{d.framebuffer.ObjectIDs, d.framebuffer.FaceIDs} {
+		if b == nil {
+			continue
+		}
+		if !d.scissorTest {
+			b.Clear(NoID)
+			continue
+		}
+		d.forEachScissored(func(i int) {
+			b.Pix[i] = NoID
+		})
+	}
+}
+
+// 以降に描くメッシュのオブジェクトID(DrawSceneではノードのIDが使われる)
+func (d *Device) SetObjectID(id uint32) {
+	d.objectID = id
+}
+
+func (d *Device) writeIDs(index int) {
+	fb := d.framebuffer
+	if fb.ObjectIDs != nil {
+		fb.ObjectIDs.Pix[index] = d.objectID
+	}
+	if fb.FaceIDs != nil {
+		fb.FaceIDs.Pix[index] = d.faceID
+	}
+}
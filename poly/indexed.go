@@ -0,0 +1,60 @@
+package poly
+
+import . "github.com/arata-nvm/poly/vecmath"
+
+// 同じ座標の頂点をまとめ、面を頂点番号で表した形
+// UVや法線は面の角ごとに、スムージンググループは面ごとに元の値を持つ
+type indexedMesh struct {
+	positions []Vector3
+	faces     [][3]int
+	corners   [][3]Vertex
+	groups    []int
+}
+
+func newIndexedMesh(m *Mesh) *indexedMesh {
+	im := &indexedMesh{
+		faces:   make([][3]int, len(m.Faces)),
+		corners: make([][3]Vertex, len(m.Faces)),
+		groups:  make([]int, len(m.Faces)),
+	}
+
+	index := make(map[Vector3]int)
+	lookup := func(p Vector3) int {
+		if i, ok := index[p]; ok {
+			return i
+		}
+		index[p] = len(im.positions)
+		im.positions = append(im.positions, p)
+		return index[p]
+	}
+
+	for i, f := range m.Faces {
+		im.faces[i] = [3]int{lookup(f.V1.Coordinates), lookup(f.V2.Coordinates), lookup(f.V3.Coordinates)}
+		im.corners[i] = [3]Vertex{f.V1, f.V2, f.V3}
+		im.groups[i] = f.SmoothingGroup
+	}
+	return im
+}
+
+// 各頂点に接する面の番号
+func (im *indexedMesh) vertexFaces() [][]int {
+	vf := make([][]int, len(im.positions))
+	for i, f := range im.faces {
+		for _, v := range f {
+			vf[v] = append(vf[v], i)
+		}
+	}
+	return vf
+}
+
+func (im *indexedMesh) toFaces() []*Face {
+	faces := make([]*Face, len(im.faces))
+	for i, f := range im.faces {
+		c := im.corners[i]
+		c[0].Coordinates = im.positions[f[0]]
+		c[1].Coordinates = im.positions[f[1]]
+		c[2].Coordinates = im.positions[f[2]]
+		faces[i] = &Face{V1: c[0], V2: c[1], V3: c[2], SmoothingGroup: im.groups[i]}
+	}
+	return faces
+}
@@ -0,0 +1,289 @@
+package poly
+
+import (
+	"math"
+
+	. "github.com/arata-nvm/poly/vecmath"
+)
+
+// 値がisoより小さい領域を内側とみなす(符号付き距離関数と同じ)
+type ScalarField interface {
+	Value(p Vector3) float64
+}
+
+type FieldFunc func(p Vector3) float64
+
+func (f FieldFunc) Value(p Vector3) float64 {
+	return f(p)
+}
+
+// 格子点に値を持ち、その間を三重線形補間するスカラー場
+type VoxelGrid struct {
+	Bounds     AABB
+	NX, NY, NZ int
+
+	Values []float64
+}
+
+// 各軸にnx, ny, nz個の格子点を持つ(両端を含む)
+func NewVoxelGrid(bounds AABB, nx, ny, nz int) *VoxelGrid {
+	return &VoxelGrid{
+		Bounds: bounds,
+		NX:     nx,
+		NY:     ny,
+		NZ:     nz,
+		Values: make([]float64, nx*ny*nz),
+	}
+}
+
+// スカラー場を格子点で標本化する
+func SampleVoxelGrid(field ScalarField, bounds AABB, nx, ny, nz int) *VoxelGrid {
+	g := NewVoxelGrid(bounds, nx, ny, nz)
+	for k := 0; k < nz; k++ {
+		for j := 0; j < ny; j++ {
+			for i := 0; i < nx; i++ {
+				g.Set(i, j, k, field.Value(g.Point(i, j, k)))
+			}
+		}
+	}
+	return g
+}
+
+func (g *VoxelGrid) Get(i, j, k int) float64 {
+	i = Max(0, Min(i, g.NX-1))
+	j = Max(0, Min(j, g.NY-1))
+	k = Max(0, Min(k, g.NZ-1))
+	return g.Values[i+g.NX*(j+g.NY*k)]
+}
+
+func (g *VoxelGrid) Set(i, j, k int, v float64) {
+	g.Values[i+g.NX*(j+g.NY*k)] = v
+}
+
+func (g *VoxelGrid) Point(i, j, k int) Vector3 {
+	s := g.Bounds.Size()
+	return g.Bounds.Min.Add(NewVector3(
+		s.X*float64(i)/float64(Max(g.NX-1, 1)),
+		s.Y*float64(j)/float64(Max(g.NY-1, 1)),
+		s.Z*float64(k)/float64(Max(g.NZ-1, 1)),
+	))
+}
+
+func (g *VoxelGrid) Value(p Vector3) float64 {
+	s := g.Bounds.Size()
+	q := p.Sub(g.Bounds.Min)
+	x := gridCoord(q.X, s.X, g.NX)
+	y := gridCoord(q.Y, s.Y, g.NY)
+	z := gridCoord(q.Z, s.Z, g.NZ)
+
+	i, j, k := int(x), int(y), int(z)
+	fx, fy, fz := x-float64(i), y-float64(j), z-float64(k)
+
+	lerp := Interpolate
+	c00 := lerp(g.Get(i, j, k), g.Get(i+1, j, k), fx)
+	c10 := lerp(g.Get(i, j+1, k), g.Get(i+1, j+1, k), fx)
+	c01 := lerp(g.Get(i, j, k+1), g.Get(i+1, j, k+1), fx)
+	c11 := lerp(g.Get(i, j+1, k+1), g.Get(i+1, j+1, k+1), fx)
+	return lerp(lerp(c00, c10, fy), lerp(c01, c11, fy), fz)
+}
+
+func gridCoord(x, size float64, n int) float64 {
+	if size <= 0 || n < 2 {
+		return 0
+	}
+	return Clamp(x/size*float64(n-1), 0, float64(n-1))
+}
+
+// 中心差分による勾配。内側が負の場なら外向きになる
+func FieldGradient(field ScalarField, p Vector3, h float64) Vector3 {
+	dx := NewVector3(h, 0, 0)
+	dy := NewVector3(0, h, 0)
+	dz := NewVector3(0, 0, h)
+	return NewVector3(
+		field.Value(p.Add(dx))-field.Value(p.Sub(dx)),
+		field.Value(p.Add(dy))-field.Value(p.Sub(dy)),
+		field.Value(p.Add(dz))-field.Value(p.Sub(dz)),
+	).DivScalar(2 * h)
+}
+
+// マーチングキューブ法で等値面を三角形に分割する
+// resolutionは最も長い軸の分割数で、他の軸は立方体に近くなるように分割する
+// 法線は場の勾配から求める
+// 格子点の値だけを見るので、セルより細い部分は欠けたり消えたりする(細いトーラスでは面が0枚になることもある)
+// 形状の最も細い部分に少なくとも2セル入るようにresolutionを選ぶ
+func MarchingCubes(field ScalarField, bounds AABB, resolution int, iso float64) *Mesh {
+	m := NewMesh()
+	size := bounds.Size()
+	longest := math.Max(size.X, math.Max(size.Y, size.Z))
+	if bounds.IsEmpty() || longest <= 0 || resolution < 1 {
+		return m
+	}
+
+	cells := func(s float64) int {
+		return Max(1, int(math.Ceil(s/longest*float64(resolution))))
+	}
+	nx, ny, nz := cells(size.X), cells(size.Y), cells(size.Z)
+	step := NewVector3(size.X/float64(nx), size.Y/float64(ny), size.Z/float64(nz))
+	h := math.Min(step.X, math.Min(step.Y, step.Z)) / 4
+
+	point := func(i, j, k int) Vector3 {
+		return bounds.Min.Add(NewVector3(float64(i)*step.X, float64(j)*step.Y, float64(k)*step.Z))
+	}
+	index := func(i, j, k int) int {
+		return i + (nx+1)*(j+(ny+1)*k)
+	}
+
+	values := make([]float64, (nx+1)*(ny+1)*(nz+1))
+	for k := 0; k <= nz; k++ {
+		for j := 0; j <= ny; j++ {
+			for i := 0; i <= nx; i++ {
+				values[index(i, j, k)] = field.Value(point(i, j, k))
+			}
+		}
+	}
+
+	normals := make(map[Vector3]Vector3)
+	vertex := func(i, j, k, edge int) Vertex {
+		a, b := mcEdges[edge][0], mcEdges[edge][1]
+		ia, ja, ka := i+a&1, j+a>>1&1, k+a>>2&1
+		ib, jb, kb := i+b&1, j+b>>1&1, k+b>>2&1
+		va, vb := values[index(ia, ja, ka)], values[index(ib, jb, kb)]
+		pa, pb := point(ia, ja, ka), point(ib, jb, kb)
+
+		// 格子点の値がちょうどisoだと複数の辺の頂点が重なって面が潰れるので、端から少し離す
+		t := Clamp((iso-va)/(vb-va), 1e-4, 1-1e-4)
+		p := pa.Add(pb.Sub(pa).MulScalar(t))
+
+		n, ok := normals[p]
+		if !ok {
+			n = FieldGradient(field, p, h)
+			if n.LengthSq() > 0 {
+				n = n.Normalize()
+			}
+			normals[p] = n
+		}
+		return Vertex{Coordinates: p, Normal: n}
+	}
+
+	for k := 0; k < nz; k++ {
+		for j := 0; j < ny; j++ {
+			for i := 0; i < nx; i++ {
+				config := 0
+				for c := 0; c < 8; c++ {
+					if values[index(i+c&1, j+c>>1&1, k+c>>2&1)] < iso {
+						config |= 1 << c
+					}
+				}
+
+				for _, tri := range mcTriangles[config] {
+					m.Faces = append(m.Faces, &Face{
+						V1: vertex(i, j, k, tri[0]),
+						V2: vertex(i, j, k, tri[1]),
+						V3: vertex(i, j, k, tri[2]),
+					})
+				}
+			}
+		}
+	}
+
+	return m
+}
+
+// 立方体の頂点cは(c&1, c>>1&1, c>>2&1)にあり、辺は1つの軸だけ異なる2頂点を結ぶ
+var mcEdges = buildMarchingCubesEdges()
+
+// 内側にある頂点の組み合わせ(8ビット)ごとの三角形。各三角形は辺の番号で表す
+var mcTriangles = buildMarchingCubesTable()
+
+func buildMarchingCubesEdges() [][2]int {
+	var edges [][2]int
+	for a := 0; a < 8; a++ {
+		for axis := 0; axis < 3; axis++ {
+			if a&(1<<axis) == 0 {
+				edges = append(edges, [2]int{a, a | 1<<axis})
+			}
+		}
+	}
+	return edges
+}
+
+// 表を手で書く代わりに、立方体の各面で等値線の線分を求め、それをつないだ閉路を扇状に分割して作る
+// 曖昧な面では外側の頂点を切り離すように線分を選ぶ。この選び方は面を共有する隣の立方体でも同じになるので、
+// できる曲面に穴は開かない
+func buildMarchingCubesTable() [256][][3]int {
+	edgeOf := make(map[[2]int]int)
+	for i, e := range mcEdges {
+		edgeOf[e] = i
+		edgeOf[[2]int{e[1], e[0]}] = i
+	}
+
+	corner := func(c int) Vector3 {
+		return NewVector3(float64(c&1), float64(c>>1&1), float64(c>>2&1))
+	}
+
+	// 各面の頂点を、立方体の外から見て反時計回りに並べる
+	var faces [][4]int
+	for axis := 0; axis < 3; axis++ {
+		u, v := 1<<((axis+1)%3), 1<<((axis+2)%3)
+		for side := 0; side < 2; side++ {
+			base := side << axis
+			f := [4]int{base, base | u, base | u | v, base | v}
+
+			outward := NewVector3(0, 0, 0)
+			switch axis {
+			case 0:
+				outward.X = float64(2*side - 1)
+			case 1:
+				outward.Y = float64(2*side - 1)
+			case 2:
+				outward.Z = float64(2*side - 1)
+			}
+			p0, p1, p2 := corner(f[0]), corner(f[1]), corner(f[2])
+			if p1.Sub(p0).Cross(p2.Sub(p0)).Dot(outward) < 0 {
+				f[1], f[3] = f[3], f[1]
+			}
+			faces = append(faces, f)
+		}
+	}
+
+	var table [256][][3]int
+	for config := 0; config < 256; config++ {
+		inside := func(c int) bool {
+			return config&(1<<c) != 0
+		}
+
+		// 内側から外側へ出る辺から、次に外側から内側へ入る辺へ線分を引く
+		next := make(map[int]int)
+		for _, f := range faces {
+			for i := 0; i < 4; i++ {
+				if !inside(f[i]) || inside(f[(i+1)%4]) {
+					continue
+				}
+				for j := 1; j < 4; j++ {
+					a, b := f[(i+j)%4], f[(i+j+1)%4]
+					if !inside(a) && inside(b) {
+						next[edgeOf[[2]int{f[i], f[(i+1)%4]}]] = edgeOf[[2]int{a, b}]
+						break
+					}
+				}
+			}
+		}
+
+		visited := make(map[int]bool)
+		for e := range mcEdges {
+			if _, ok := next[e]; !ok || visited[e] {
+				continue
+			}
+
+			var loop []int
+			for cur := e; !visited[cur]; cur = next[cur] {
+				visited[cur] = true
+				loop = append(loop, cur)
+			}
+			for i := 2; i < len(loop); i++ {
+				table[config] = append(table[config], [3]int{loop[0], loop[i], loop[i-1]})
+			}
+		}
+	}
+	return table
+}
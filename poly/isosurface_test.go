@@ -0,0 +1,70 @@
+package poly
+
+import (
+	"math"
+	"testing"
+
+	. "github.com/arata-nvm/poly/vecmath"
+)
+
+func TestMarchingCubesClosedAndOutward(t *testing.T) {
+	cases := []struct {
+		name       string
+		field      ScalarField
+		bounds     AABB
+		resolution int
+		// 0なら体積は確かめない
+		volume float64
+	}{
+		{
+			name:       "sphere",
+			field:      SphereSDF(Zero(), 1),
+			bounds:     AABB{Min: NewVector3(-1.5, -1.5, -1.5), Max: NewVector3(1.5, 1.5, 1.5)},
+			resolution: 24,
+			volume:     4.0 / 3 * math.Pi,
+		},
+		{
+			name:       "torus",
+			field:      TorusSDF(Zero(), 1, 0.4),
+			bounds:     AABB{Min: NewVector3(-1.6, -0.6, -1.6), Max: NewVector3(1.6, 0.6, 1.6)},
+			resolution: 40,
+			volume:     2 * math.Pi * math.Pi * 1 * 0.4 * 0.4,
+		},
+		{
+			name:       "box minus sphere",
+			field:      SubtractSDF(BoxSDF(Zero(), NewVector3(1, 1, 1)), SphereSDF(NewVector3(1, 1, 1), 1)),
+			bounds:     AABB{Min: NewVector3(-1.5, -1.5, -1.5), Max: NewVector3(1.5, 1.5, 1.5)},
+			resolution: 24,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := MarchingCubes(c.field, c.bounds, c.resolution, 0)
+			if len(m.Faces) == 0 {
+				t.Fatal("no faces")
+			}
+			if r := m.Validate(); !r.OK() {
+				t.Fatalf("mesh is not closed and consistently wound: %+v", r)
+			}
+
+			v := m.Volume()
+			if v <= 0 {
+				t.Fatalf("volume = %v, faces point inward", v)
+			}
+			if c.volume > 0 && math.Abs(v-c.volume) > 0.03*c.volume {
+				t.Errorf("volume = %v, want about %v", v, c.volume)
+			}
+
+			// 面の向きと、場の勾配から求めた頂点法線が揃っている
+			for i, f := range m.Faces {
+				n := f.Normal()
+				for _, vert := range []Vertex{f.V1, f.V2, f.V3} {
+					if n.Dot(vert.Normal) <= 0 {
+						t.Fatalf("face %d: vertex normal %v opposes face normal %v", i, vert.Normal, n)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package poly
+
+import (
+	"math"
+
+	. "github.com/arata-nvm/poly/vecmath"
+)
+
+type Light interface {
+	// 点pから光源への方向と、pに届く光の強さを返す
+	Illuminate(p Vector3) (Vector3, Color)
+}
+
+type DirectionalLight struct {
+	Direction Vector3
+	Color     Color
+}
+
+func NewDirectionalLight(direction Vector3, color Color) *DirectionalLight {
+	return &DirectionalLight{
+		Direction: direction.Normalize(),
+		Color:     color,
+	}
+}
+
+func (l *DirectionalLight) Illuminate(_ Vector3) (Vector3, Color) {
+	return l.Direction, l.Color
+}
+
+type PointLight struct {
+	Position  Vector3
+	Color     Color
+	Intensity float64
+}
+
+func NewPointLight(position Vector3, color Color, intensity float64) *PointLight {
+	return &PointLight{
+		Position:  position,
+		Color:     color,
+		Intensity: intensity,
+	}
+}
+
+func (l *PointLight) Illuminate(p Vector3) (Vector3, Color) {
+	d := l.Position.Sub(p)
+	distSq := math.Max(d.LengthSq(), 1e-4)
+	return d.Normalize(), l.Color.MulScalar(l.Intensity / distSq)
+}
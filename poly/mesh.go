@@ -4,10 +4,17 @@ import . "github.com/arata-nvm/poly/vecmath"
 
 type Mesh struct {
 	Faces []*Face
+	// 元の多角形ごとの頂点数。多角形はFacesの連続する(頂点数-2)個の三角形に扇状に分割されている
+	// nilならすべての面が三角形
+	Polygons []int
 
 	Position Vector3
 	Rotation Vector3
 	Scale    Vector3
+
+	bvh     *BVH
+	creases map[[2]Vector3]bool
+	normals *normalSettings
 }
 
 func NewMesh() *Mesh {
@@ -18,6 +25,58 @@ func NewMesh() *Mesh {
 	}
 }
 
+// 面も複製するので、変更しても元のメッシュには影響しない
+func (m *Mesh) Clone() *Mesh {
+	c := NewMesh()
+	c.Position, c.Rotation, c.Scale = m.Position, m.Rotation, m.Scale
+	c.Polygons = append([]int(nil), m.Polygons...)
+	for e := range m.creases {
+		c.AddCrease(e[0], e[1])
+	}
+	c.normals = m.normals
+	c.Faces = make([]*Face, len(m.Faces))
+	for i, f := range m.Faces {
+		face := *f
+		c.Faces[i] = &face
+	}
+	return c
+}
+
+// 面を変更した後は作り直す必要がある
+func (m *Mesh) BuildBVH() *BVH {
+	bounds := make([]AABB, len(m.Faces))
+	for i, f := range m.Faces {
+		bounds[i] = EmptyAABB().Extend(f.V1.Coordinates).Extend(f.V2.Coordinates).Extend(f.V3.Coordinates)
+	}
+	m.bvh = NewBVH(bounds)
+	return m.bvh
+}
+
+func (m *Mesh) BVH() *BVH {
+	return m.bvh
+}
+
+func (m *Mesh) localBounds() AABB {
+	if m.bvh != nil {
+		return m.bvh.Bounds()
+	}
+	return m.BoundingBox()
+}
+
+func (m *Mesh) Transform() Transform {
+	return NewTransform(m.Position, QuaternionFromEuler(m.Rotation), m.Scale)
+}
+
+func (m *Mesh) SetTransform(t Transform) {
+	m.Position = t.Translation
+	m.Rotation = t.Rotation.Euler()
+	m.Scale = t.Scale
+}
+
+func (m *Mesh) ModelMatrix() Matrix4 {
+	return m.Transform().Matrix()
+}
+
 func (m *Mesh) SmoothNormals() {
 	// 同じ位置にある頂点の法線ベクトルの総和をとり、正規化
 	normals := make(map[Vector3]Vector3)
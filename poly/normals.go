@@ -0,0 +1,117 @@
+package poly
+
+import (
+	"math"
+
+	. "github.com/arata-nvm/poly/vecmath"
+)
+
+// 頂点法線を求めるときの、接する面の法線の重み
+type NormalWeighting int
+
+const (
+	NormalWeightEqual NormalWeighting = iota
+	NormalWeightArea
+	NormalWeightAngle
+)
+
+// 面の法線から頂点法線を計算し直す
+// 法線の向きの差がcreaseAngle(度数法)を超える面同士と、スムージンググループの異なる面同士では
+// 法線を共有しないので、その辺は角張ったまま残る。180以上を指定すると常に共有する
+// 頂点は座標の一致で同一視するので、必要ならWeldを先に呼ぶ
+// 設定は記録され、細分割や簡略化の後に法線を計算し直すときにも使われる
+func (m *Mesh) ComputeNormals(weighting NormalWeighting, creaseAngle float64) {
+	m.normals = &normalSettings{weighting: weighting, creaseAngle: creaseAngle}
+
+	threshold := math.Cos(creaseAngle * math.Pi / 180)
+	if creaseAngle >= 180 {
+		threshold = math.Inf(-1)
+	}
+
+	type corner struct {
+		face   int
+		normal Vector3
+		weight float64
+	}
+
+	normals := make([]Vector3, len(m.Faces))
+	corners := make(map[Vector3][]corner)
+	for i, f := range m.Faces {
+		n := f.V2.Coordinates.Sub(f.V1.Coordinates).Cross(f.V3.Coordinates.Sub(f.V1.Coordinates))
+		area := n.Length() / 2
+		if area > 0 {
+			normals[i] = n.Normalize()
+		}
+		vs := [3]Vector3{f.V1.Coordinates, f.V2.Coordinates, f.V3.Coordinates}
+		for k, p := range vs {
+			w := 1.0
+			switch weighting {
+			case NormalWeightArea:
+				w = area
+			case NormalWeightAngle:
+				w = cornerAngle(p, vs[(k+1)%3], vs[(k+2)%3])
+			}
+			corners[p] = append(corners[p], corner{i, normals[i], w})
+		}
+	}
+
+	smooth := func(i int, p Vector3) Vector3 {
+		f := m.Faces[i]
+		if f.SmoothingGroup == SmoothingOff {
+			return normals[i]
+		}
+
+		sum := Zero()
+		for _, c := range corners[p] {
+			if m.Faces[c.face].SmoothingGroup != f.SmoothingGroup {
+				continue
+			}
+			// 面積0の面は向きを持たないので、周りの面をすべて使う
+			if c.face != i && normals[i].LengthSq() > 0 && normals[i].Dot(c.normal) < threshold {
+				continue
+			}
+			sum = sum.Add(c.normal.MulScalar(c.weight))
+		}
+		if sum.LengthSq() == 0 {
+			return normals[i]
+		}
+		return sum.Normalize()
+	}
+
+	result := make([][3]Vector3, len(m.Faces))
+	for i, f := range m.Faces {
+		result[i] = [3]Vector3{
+			smooth(i, f.V1.Coordinates),
+			smooth(i, f.V2.Coordinates),
+			smooth(i, f.V3.Coordinates),
+		}
+	}
+	for i, f := range m.Faces {
+		f.V1.Normal, f.V2.Normal, f.V3.Normal = result[i][0], result[i][1], result[i][2]
+	}
+}
+
+type normalSettings struct {
+	weighting   NormalWeighting
+	creaseAngle float64
+}
+
+// 最後にComputeNormalsに渡した設定で法線を計算し直す
+// 一度も呼ばれていなければ、角度で重み付けしてスムージンググループの中をすべて滑らかにする
+func (m *Mesh) recomputeNormals() {
+	s := normalSettings{weighting: NormalWeightAngle, creaseAngle: 180}
+	if m.normals != nil {
+		s = *m.normals
+	}
+	m.ComputeNormals(s.weighting, s.creaseAngle)
+}
+
+// 頂点pにおける三角形の内角
+func cornerAngle(p, a, b Vector3) float64 {
+	u, v := a.Sub(p), b.Sub(p)
+	l := u.Length() * v.Length()
+	if l == 0 {
+		return 0
+	}
+	return math.Acos(Clamp(u.Dot(v)/l, -1, 1))
+}
@@ -25,6 +25,8 @@ func parseObj(r io.Reader) *Mesh {
 	vertices := make([]Vector3, 0)
 	uvs := make([]Vector3, 0)
 	normals := make([]Vector3, 0)
+	hasPolygons := false
+	group := 0
 
 	s := bufio.NewScanner(r)
 	for s.Scan() {
@@ -37,35 +39,47 @@ func parseObj(r io.Reader) *Mesh {
 			uvs = append(uvs, parseUv(cols))
 		case "vn":
 			normals = append(normals, parseNormal(cols))
+		case "s":
+			group = parseSmoothingGroup(cols)
 		case "f":
-			col1 := parseFaceIndices(cols[1])
-			col2 := parseFaceIndices(cols[2])
-			col3 := parseFaceIndices(cols[3])
-
-			v1 := Vertex{
-				Coordinates: vertices[col1[0]],
-				Uv:          uvs[col1[1]],
-				Normal:      normals[col1[2]],
+			polygon := make([]Vertex, 0, len(cols)-1)
+			for _, col := range cols[1:] {
+				if col == "" {
+					continue
+				}
+				indices := parseFaceIndices(col)
+				polygon = append(polygon, Vertex{
+					Coordinates: vertices[indices[0]],
+					Uv:          uvs[indices[1]],
+					Normal:      normals[indices[2]],
+				})
 			}
-
-			v2 := Vertex{
-				Coordinates: vertices[col2[0]],
-				Uv:          uvs[col2[1]],
-				Normal:      normals[col2[2]],
+			// 三角形にならない面は読み飛ばし、PolygonsとFacesの対応を崩さない
+			if len(polygon) < 3 {
+				continue
 			}
 
-			v3 := Vertex{
-				Coordinates: vertices[col3[0]],
-				Uv:          uvs[col3[1]],
-				Normal:      normals[col3[2]],
+			// 多角形は最初の頂点を中心に扇状に分割する
+			for i := 2; i < len(polygon); i++ {
+				o.Faces = append(o.Faces, &Face{
+					V1:             polygon[0],
+					V2:             polygon[i-1],
+					V3:             polygon[i],
+					SmoothingGroup: group,
+				})
+			}
+			o.Polygons = append(o.Polygons, len(polygon))
+			if len(polygon) > 3 {
+				hasPolygons = true
 			}
-
-			o.Faces = append(o.Faces, &Face{v1, v2, v3})
 		default:
 			continue
 		}
 	}
 
+	if !hasPolygons {
+		o.Polygons = nil
+	}
 	return o
 }
 
@@ -121,6 +135,22 @@ func parseNormal(cols []string) Vector3 {
 	return NewVector3(x, y, z)
 }
 
+// 番号のない "s" はoffとみなす
+func parseSmoothingGroup(cols []string) int {
+	if len(cols) < 2 || cols[1] == "" || cols[1] == "off" {
+		return SmoothingOff
+	}
+
+	g, err := strconv.Atoi(cols[1])
+	if err != nil {
+		panic(err)
+	}
+	if g == 0 {
+		return SmoothingOff
+	}
+	return g
+}
+
 func parseFaceIndices(col string) []int {
 	cols := strings.Split(col, "/")
 	v1, err := strconv.ParseInt(cols[0], 10, 32)
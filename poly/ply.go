@@ -2,14 +2,17 @@ package poly
 
 import (
 	"bufio"
+	"encoding/binary"
 	"io"
+	"math"
 	"os"
 	"strconv"
 	"strings"
-
-	. "github.com/arata-nvm/poly/vecmath"
 )
 
+// ascii, binary_little_endian, binary_big_endianのPLYを読み込む
+// 頂点はx, y, z, nx, ny, nzのプロパティを使い、面はvertex_indices(vertex_index)のリストを扇状に三角形へ分割する
+// 面を持たないPLYでは面のないメッシュになるので、頂点はLoadPlyPointCloudで読む
 func LoadPly(filename string) *Mesh {
 	f, err := os.Open(filename)
 	if err != nil {
@@ -21,109 +24,299 @@ func LoadPly(filename string) *Mesh {
 }
 
 func parsePly(r io.Reader) *Mesh {
+	points, faces := parsePlyData(r)
+
 	o := NewMesh()
+	hasPolygons := false
+	for _, face := range faces {
+		// 三角形にならない面は読み飛ばし、PolygonsとFacesの対応を崩さない
+		if len(face) < 3 {
+			continue
+		}
+		polygon := make([]Vertex, len(face))
+		for i, index := range face {
+			if index < 0 || index >= len(points) {
+				panic("ply: vertex index out of range: " + strconv.Itoa(index))
+			}
+			polygon[i] = Vertex{
+				Coordinates: points[index].Position,
+				Normal:      points[index].Normal,
+			}
+		}
 
-	s := bufio.NewScanner(r)
-	numVertex, numFace := parseHeader(s)
+		for i := 2; i < len(polygon); i++ {
+			o.Faces = append(o.Faces, &Face{V1: polygon[0], V2: polygon[i-1], V3: polygon[i]})
+		}
+		o.Polygons = append(o.Polygons, len(polygon))
+		if len(polygon) > 3 {
+			hasPolygons = true
+		}
+	}
 
-	vertices := make([]Vector3, 0, numVertex)
-	o.Faces = make([]*Face, 0, numFace)
+	if !hasPolygons {
+		o.Polygons = nil
+	}
+	return o
+}
 
-	for i := 0; i < numVertex; i++ {
-		s.Scan()
-		line := s.Text()
-		cols := strings.Split(line, " ")
-		vertex := parsePlyVertex(cols)
-		vertices = append(vertices, vertex)
+// 面を持たないPLYも読めるよう、頂点だけを点群として読み込む
+// LoadPlyと同じ形式に対応し、x, y, z, nx, ny, nz, red, green, blue, alphaのプロパティを使う
+// 整数型の色はsRGBとみなして線形に変換する。色がなければ白にする
+func LoadPlyPointCloud(filename string) *PointCloud {
+	f, err := os.Open(filename)
+	if err != nil {
+		panic(err)
 	}
+	defer f.Close()
+
+	return parsePlyPointCloud(f)
+}
 
-	for i := 0; i < numFace; i++ {
-		s.Scan()
-		line := s.Text()
-		cols := strings.Split(line, " ")
-		face := parsePlyFace(cols)
+type plyProperty struct {
+	name string
+	typ  string
+	// リストの場合の要素数の型。リストでなければ空
+	countType string
+}
+
+type plyElement struct {
+	name       string
+	count      int
+	properties []plyProperty
+}
+
+func parsePlyPointCloud(r io.Reader) *PointCloud {
+	pc := NewPointCloud()
+	pc.Points, _ = parsePlyData(r)
+	return pc
+}
 
-		v1 := Vertex{
-			Coordinates: vertices[face[0]],
+// 頂点と、各面の頂点番号を読み込む。面がなければfacesは空になる
+func parsePlyData(r io.Reader) ([]Point, [][]int) {
+	br := bufio.NewReader(r)
+	format, elements := parsePlyElements(br)
+	pr := &plyReader{r: br, format: format}
+
+	var points []Point
+	var faces [][]int
+	for _, e := range elements {
+		for i := 0; i < e.count; i++ {
+			values, lists := pr.readElement(e)
+			switch e.name {
+			case "vertex":
+				points = append(points, plyPoint(e, values))
+			case "face":
+				faces = append(faces, plyFace(e, lists))
+			}
 		}
-		v2 := Vertex{
-			Coordinates: vertices[face[1]],
+	}
+	return points, faces
+}
+
+func parsePlyElements(r *bufio.Reader) (string, []plyElement) {
+	var format string
+	var elements []plyElement
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			panic(err)
 		}
-		v3 := Vertex{
-			Coordinates: vertices[face[2]],
+		cols := strings.Fields(line)
+		if len(cols) == 0 {
+			continue
 		}
 
-		o.Faces = append(o.Faces, &Face{v1, v2, v3})
+		switch cols[0] {
+		case "format":
+			format = cols[1]
+		case "element":
+			count, err := strconv.Atoi(cols[2])
+			if err != nil {
+				panic(err)
+			}
+			elements = append(elements, plyElement{name: cols[1], count: count})
+		case "property":
+			if len(elements) == 0 {
+				panic("ply: property before element")
+			}
+			e := &elements[len(elements)-1]
+			if cols[1] == "list" {
+				e.properties = append(e.properties, plyProperty{name: cols[4], typ: cols[3], countType: cols[2]})
+			} else {
+				e.properties = append(e.properties, plyProperty{name: cols[2], typ: cols[1]})
+			}
+		case "end_header":
+			return format, elements
+		}
 	}
+}
 
-	return o
+type plyReader struct {
+	r      *bufio.Reader
+	format string
+	fields []string
+	// バイナリの値を読むための作業領域
+	buf [8]byte
 }
 
-func parseHeader(s *bufio.Scanner) (int, int) {
-	var numVertex int
-	var numFace int
-	var err error
+// リストのプロパティの値はlistsに入れ、valuesにはNaNを入れる
+func (pr *plyReader) readElement(e plyElement) ([]float64, [][]float64) {
+	if pr.format == "ascii" {
+		line, err := pr.r.ReadString('\n')
+		if err != nil && line == "" {
+			panic(err)
+		}
+		pr.fields = strings.Fields(line)
+	}
 
-	for s.Scan() {
-		line := s.Text()
-		if line == "end_header" {
-			break
+	values := make([]float64, len(e.properties))
+	lists := make([][]float64, len(e.properties))
+	for i, p := range e.properties {
+		if p.countType == "" {
+			values[i] = pr.read(p.typ)
+			continue
 		}
+		n := int(pr.read(p.countType))
+		lists[i] = make([]float64, n)
+		for k := range lists[i] {
+			lists[i][k] = pr.read(p.typ)
+		}
+		values[i] = math.NaN()
+	}
+	return values, lists
+}
 
-		cols := strings.Split(line, " ")
-		if cols[0] == "element" {
-			switch cols[1] {
-			case "vertex":
-				numVertex, err = strconv.Atoi(cols[2])
-				if err != nil {
-					panic(err)
-				}
-			case "face":
-				numFace, err = strconv.Atoi(cols[2])
-				if err != nil {
-					panic(err)
-				}
-			}
+func (pr *plyReader) read(typ string) float64 {
+	if pr.format == "ascii" {
+		v, err := strconv.ParseFloat(pr.fields[0], 64)
+		if err != nil {
+			panic(err)
 		}
+		pr.fields = pr.fields[1:]
+		return v
 	}
 
-	return numVertex, numFace
-}
+	var order binary.ByteOrder = binary.LittleEndian
+	if pr.format == "binary_big_endian" {
+		order = binary.BigEndian
+	}
 
-func parsePlyVertex(cols []string) Vector3 {
-	x, err := strconv.ParseFloat(cols[0], 32)
-	if err != nil {
+	buf := pr.buf[:plyTypeSize(typ)]
+	if _, err := io.ReadFull(pr.r, buf); err != nil {
 		panic(err)
 	}
 
-	y, err := strconv.ParseFloat(cols[1], 32)
-	if err != nil {
-		panic(err)
+	switch typ {
+	case "char", "int8":
+		return float64(int8(buf[0]))
+	case "uchar", "uint8":
+		return float64(buf[0])
+	case "short", "int16":
+		return float64(int16(order.Uint16(buf)))
+	case "ushort", "uint16":
+		return float64(order.Uint16(buf))
+	case "int", "int32":
+		return float64(int32(order.Uint32(buf)))
+	case "uint", "uint32":
+		return float64(order.Uint32(buf))
+	case "float", "float32":
+		return float64(math.Float32frombits(order.Uint32(buf)))
+	case "double", "float64":
+		return math.Float64frombits(order.Uint64(buf))
 	}
+	panic("ply: unknown property type " + typ)
+}
 
-	z, err := strconv.ParseFloat(cols[2], 32)
-	if err != nil {
-		panic(err)
+func plyTypeSize(typ string) int {
+	switch typ {
+	case "char", "int8", "uchar", "uint8":
+		return 1
+	case "short", "int16", "ushort", "uint16":
+		return 2
+	case "double", "float64":
+		return 8
+	}
+	return 4
+}
+
+func plyPoint(e plyElement, values []float64) Point {
+	p := Point{Color: WHITE}
+	for i, prop := range e.properties {
+		v := values[i]
+		switch prop.name {
+		case "x":
+			p.Position.X = v
+		case "y":
+			p.Position.Y = v
+		case "z":
+			p.Position.Z = v
+		case "nx":
+			p.Normal.X = v
+		case "ny":
+			p.Normal.Y = v
+		case "nz":
+			p.Normal.Z = v
+		case "red":
+			p.Color.R = plyColor(v, prop.typ)
+		case "green":
+			p.Color.G = plyColor(v, prop.typ)
+		case "blue":
+			p.Color.B = plyColor(v, prop.typ)
+		case "alpha":
+			p.Color.A = plyUnit(v, prop.typ)
+		}
 	}
+	return p
+}
 
-	return NewVector3(x, y, z)
+func plyFace(e plyElement, lists [][]float64) []int {
+	for i, prop := range e.properties {
+		if prop.name != "vertex_indices" && prop.name != "vertex_index" {
+			continue
+		}
+		face := make([]int, len(lists[i]))
+		for k, v := range lists[i] {
+			face[k] = int(v)
+		}
+		return face
+	}
+	return nil
 }
 
-func parsePlyFace(cols []string) []int {
-	v1, err := strconv.Atoi(cols[1])
-	if err != nil {
-		panic(err)
+// 整数型の色はsRGBから線形に変換し、浮動小数点型の色はそのまま使う
+func plyColor(v float64, typ string) float64 {
+	if plyIsFloat(typ) {
+		return v
 	}
+	return SRGBToLinear(plyUnit(v, typ))
+}
 
-	v2, err := strconv.Atoi(cols[2])
-	if err != nil {
-		panic(err)
+// 整数型の値を型の最大値で割って0から1にする
+// 符号付きの型は符号付きの最大値で割り、負の値は0にする
+func plyUnit(v float64, typ string) float64 {
+	if plyIsFloat(typ) {
+		return v
 	}
+	bits := 8 * plyTypeSize(typ)
+	if plyIsSigned(typ) {
+		return math.Max(v, 0) / float64(int(1)<<(bits-1)-1)
+	}
+	return v / float64(int(1)<<bits-1)
+}
 
-	v3, err := strconv.Atoi(cols[3])
-	if err != nil {
-		panic(err)
+func plyIsSigned(typ string) bool {
+	switch typ {
+	case "char", "int8", "short", "int16", "int", "int32":
+		return true
 	}
+	return false
+}
 
-	return []int{v1, v2, v3}
+func plyIsFloat(typ string) bool {
+	switch typ {
+	case "float", "float32", "double", "float64":
+		return true
+	}
+	return false
 }
@@ -0,0 +1,193 @@
+package poly
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	. "github.com/arata-nvm/poly/vecmath"
+)
+
+type plyTestProperty struct {
+	name, typ string
+}
+
+var plyTestVertexProperties = []plyTestProperty{
+	{"x", "float"}, {"y", "double"}, {"z", "short"},
+	{"nx", "float"}, {"ny", "float"}, {"nz", "float"},
+	{"red", "uchar"}, {"green", "uchar"}, {"blue", "uchar"}, {"alpha", "char"},
+}
+
+// 正方形の4頂点と、四角形、2頂点だけの面、三角形の3つの面
+var plyTestVertices = [][]float64{
+	{0, 0, 0, 0, 0, 1, 255, 0, 0, 127},
+	{1, 0, 0, 0, 0, 1, 0, 255, 0, -5},
+	{1, 1, 0, 0, 0, 1, 0, 0, 255, 127},
+	{0, 1, 0, 0, 0, 1, 255, 255, 255, 0},
+}
+
+var plyTestFaces = [][]int{{0, 1, 2, 3}, {0, 1}, {0, 2, 3}}
+
+// 同じ内容のPLYを指定した形式で作る
+func plySample(format string) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "ply\nformat %s 1.0\ncomment test\n", format)
+	fmt.Fprintf(&b, "element vertex %d\n", len(plyTestVertices))
+	for _, p := range plyTestVertexProperties {
+		fmt.Fprintf(&b, "property %s %s\n", p.typ, p.name)
+	}
+	fmt.Fprintf(&b, "element face %d\nproperty list uchar int vertex_indices\nend_header\n", len(plyTestFaces))
+
+	if format == "ascii" {
+		for _, v := range plyTestVertices {
+			cols := make([]string, len(v))
+			for i, x := range v {
+				cols[i] = fmt.Sprint(x)
+			}
+			b.WriteString(strings.Join(cols, " ") + "\n")
+		}
+		for _, f := range plyTestFaces {
+			fmt.Fprint(&b, len(f))
+			for _, i := range f {
+				fmt.Fprintf(&b, " %d", i)
+			}
+			b.WriteString("\n")
+		}
+		return b.Bytes()
+	}
+
+	var order binary.ByteOrder = binary.LittleEndian
+	if format == "binary_big_endian" {
+		order = binary.BigEndian
+	}
+	write := func(v interface{}) {
+		if err := binary.Write(&b, order, v); err != nil {
+			panic(err)
+		}
+	}
+	for _, v := range plyTestVertices {
+		for i, p := range plyTestVertexProperties {
+			switch p.typ {
+			case "float":
+				write(float32(v[i]))
+			case "double":
+				write(v[i])
+			case "short":
+				write(int16(v[i]))
+			case "uchar":
+				write(uint8(v[i]))
+			case "char":
+				write(int8(v[i]))
+			}
+		}
+	}
+	for _, f := range plyTestFaces {
+		write(uint8(len(f)))
+		for _, i := range f {
+			write(int32(i))
+		}
+	}
+	return b.Bytes()
+}
+
+var plyTestFormats = []string{"ascii", "binary_little_endian", "binary_big_endian"}
+
+func TestParsePly(t *testing.T) {
+	for _, format := range plyTestFormats {
+		t.Run(format, func(t *testing.T) {
+			m := parsePly(bytes.NewReader(plySample(format)))
+
+			// 2頂点の面は読み飛ばすので、Polygonsは残りの面と対応する
+			if len(m.Faces) != 3 {
+				t.Fatalf("got %d faces, want 3", len(m.Faces))
+			}
+			if len(m.Polygons) != 2 || m.Polygons[0] != 4 || m.Polygons[1] != 3 {
+				t.Fatalf("got polygons %v, want [4 3]", m.Polygons)
+			}
+
+			want := [][3]int{{0, 1, 2}, {0, 2, 3}, {0, 2, 3}}
+			for i, f := range m.Faces {
+				for k, v := range []Vertex{f.V1, f.V2, f.V3} {
+					p := plyTestVertices[want[i][k]]
+					if v.Coordinates != NewVector3(p[0], p[1], p[2]) || v.Normal != UnitZ() {
+						t.Fatalf("face %d corner %d is %+v, want vertex %d", i, k, v, want[i][k])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestParsePlyPointCloud(t *testing.T) {
+	for _, format := range plyTestFormats {
+		t.Run(format, func(t *testing.T) {
+			pc := parsePlyPointCloud(bytes.NewReader(plySample(format)))
+			if len(pc.Points) != len(plyTestVertices) {
+				t.Fatalf("got %d points, want %d", len(pc.Points), len(plyTestVertices))
+			}
+
+			// 符号付きのalphaは127で割り、負の値は0にする
+			want := []Color{
+				NewColor(1, 0, 0, 1),
+				NewColor(0, 1, 0, 0),
+				NewColor(0, 0, 1, 1),
+				NewColor(1, 1, 1, 0),
+			}
+			for i, p := range pc.Points {
+				c := p.Color
+				for k, pair := range [][2]float64{{c.R, want[i].R}, {c.G, want[i].G}, {c.B, want[i].B}, {c.A, want[i].A}} {
+					if !ApproxEqual(pair[0], pair[1], 1e-9) {
+						t.Fatalf("point %d channel %d is %v, want %v", i, k, pair[0], pair[1])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestParsePlyInvalid(t *testing.T) {
+	binary := plySample("binary_little_endian")
+	cases := []struct {
+		name, src string
+	}{
+		{"property before element", "ply\nformat ascii 1.0\nproperty float x\nend_header\n"},
+		{"index out of range", "ply\nformat ascii 1.0\nelement vertex 1\nproperty float x\nproperty float y\nproperty float z\n" +
+			"element face 1\nproperty list uchar int vertex_indices\nend_header\n0 0 0\n3 0 0 1\n"},
+		{"truncated binary", string(binary[:len(binary)-2])},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("did not panic")
+				}
+			}()
+			parsePly(strings.NewReader(c.src))
+		})
+	}
+}
+
+func TestPlyUnit(t *testing.T) {
+	cases := []struct {
+		v    float64
+		typ  string
+		want float64
+	}{
+		{255, "uchar", 1},
+		{65535, "ushort", 1},
+		{127, "char", 1},
+		{-128, "char", 0},
+		{32767, "short", 1},
+		{0.25, "float", 0.25},
+	}
+
+	for _, c := range cases {
+		if got := plyUnit(c.v, c.typ); math.Abs(got-c.want) > 1e-12 {
+			t.Errorf("plyUnit(%v, %s) = %v, want %v", c.v, c.typ, got, c.want)
+		}
+	}
+}
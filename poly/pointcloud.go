@@ -0,0 +1,151 @@
+package poly
+
+import (
+	"math"
+
+	. "github.com/arata-nvm/poly/vecmath"
+)
+
+// 法線が分からない点はNormalを長さ0にしておく
+type Point struct {
+	Position Vector3
+	Normal   Vector3
+	Color    Color
+}
+
+// 面を持たない点の集まり。スキャナーの出力などに使う
+type PointCloud struct {
+	Points []Point
+
+	Position Vector3
+	Rotation Vector3
+	Scale    Vector3
+}
+
+func NewPointCloud() *PointCloud {
+	return &PointCloud{
+		Position: Zero(),
+		Rotation: Zero(),
+		Scale:    Unit(),
+	}
+}
+
+func (pc *PointCloud) Transform() Transform {
+	return NewTransform(pc.Position, QuaternionFromEuler(pc.Rotation), pc.Scale)
+}
+
+func (pc *PointCloud) ModelMatrix() Matrix4 {
+	return pc.Transform().Matrix()
+}
+
+// モデル座標系でのバウンディングボックス
+func (pc *PointCloud) BoundingBox() AABB {
+	b := EmptyAABB()
+	for _, p := range pc.Points {
+		b = b.Extend(p.Position)
+	}
+	return b
+}
+
+// 点を画面に向いた円や正方形(スプラット)として描くときの形
+type PointShape int
+
+const (
+	PointRound PointShape = iota
+	PointSquare
+)
+
+// ワールド座標系での点の直径。遠くの点ほど小さく描く
+// 0なら1ピクセルの点として描く。画面上の半径はmaxSplatRadiusピクセルまでに抑える
+func (d *Device) SetPointSize(size float64) {
+	d.pointSize = size
+}
+
+func (d *Device) SetPointShape(shape PointShape) {
+	d.pointShape = shape
+}
+
+// 長さ0でなければ、法線を持つ点をこの向き(ワールド座標系で点から光源へ向かう向き)の光で照らす
+// 点群の法線は向きが揃っていないことが多いので、裏表は区別しない
+func (d *Device) SetPointLight(light Vector3) {
+	d.pointLight = light
+}
+
+// 点群を深度テスト付きのスプラットとして描く
+// シェーダーは使わず、点の色をそのまま(光源があれば陰影を付けて)書き込む
+// 面IDのバッファには点の番号を書き込む
+func (d *Device) DrawPointCloud(pc *PointCloud) {
+	model := pc.ModelMatrix()
+	modelView := d.viewMatrix.Mul(model)
+	normalMatrix := model.NormalMatrix()
+
+	light := d.pointLight
+	if light.LengthSq() > 0 {
+		light = light.Normalize()
+	}
+
+	defer func() { d.faceID = NoID }()
+
+	vp := d.viewport
+	for i, p := range pc.Points {
+		view := modelView.MulVector(p.Position)
+		clip := d.projectionMatrix.MulVector4(NewVector4FromVec(view, 1))
+		if clip.W <= 0 {
+			continue
+		}
+		ndc := clip.Homogenize()
+		if ndc.Z < -1 || ndc.Z > 1 {
+			continue
+		}
+		x, y := d.ndcToScreen(ndc.X, ndc.Y)
+
+		// 視点座標系で横にずらした点を投影し、画面上の半径を求める
+		radius := 0.0
+		if d.pointSize > 0 {
+			edge := d.projectionMatrix.MulVector4(NewVector4FromVec(view.Add(NewVector3(d.pointSize/2, 0, 0)), 1))
+			radius = math.Abs(edge.Homogenize().X-ndc.X) / 2 * float64(vp.Dx())
+		}
+
+		c := p.Color
+		if light.LengthSq() > 0 && p.Normal.LengthSq() > 0 {
+			n := normalMatrix.MulVector(p.Normal).Normalize()
+			c = c.MulScalar(0.2 + 0.8*math.Abs(n.Dot(light)))
+		}
+
+		d.faceID = uint32(i)
+		d.drawSplat(x, y, d.windowDepth(ndc.Z), radius, c)
+	}
+}
+
+// スプラットの半径の上限(ピクセル)。カメラのすぐ近くの点が画面全体を覆わないようにする
+const maxSplatRadius = 256
+
+// 画面上の(x, y)を中心とする半径radiusのスプラットを一定の深度で描く
+// 半径が小さくても中心のピクセルは必ず描く
+// 走査する範囲は描画できる矩形(ビューポートとシザー矩形)の中に限る
+func (d *Device) drawSplat(x, y, z, radius float64, c Color) {
+	if radius < 0.5 {
+		d.putPixel(int(math.Floor(x)), int(math.Floor(y)), z, c)
+		return
+	}
+	radius = math.Min(radius, maxSplatRadius)
+
+	r := d.drawableRect()
+	minX := int(math.Max(math.Floor(x-radius), float64(r.Min.X)))
+	maxX := int(math.Min(math.Floor(x+radius), float64(r.Max.X-1)))
+	minY := int(math.Max(math.Floor(y-radius), float64(r.Min.Y)))
+	maxY := int(math.Min(math.Floor(y+radius), float64(r.Max.Y-1)))
+
+	for py := minY; py <= maxY; py++ {
+		for px := minX; px <= maxX; px++ {
+			dx, dy := float64(px)+0.5-x, float64(py)+0.5-y
+			if d.pointShape == PointRound && dx*dx+dy*dy > radius*radius {
+				continue
+			}
+			if math.Abs(dx) > radius || math.Abs(dy) > radius {
+				continue
+			}
+			d.putPixel(px, py, z, c)
+		}
+	}
+}
@@ -0,0 +1,527 @@
+package poly
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+
+	. "github.com/arata-nvm/poly/vecmath"
+)
+
+type FXAA struct {
+	SpanMax   float64
+	ReduceMul float64
+	ReduceMin float64
+}
+
+func NewFXAA() *FXAA {
+	return &FXAA{
+		SpanMax:   8,
+		ReduceMul: 1.0 / 8,
+		ReduceMin: 1.0 / 128,
+	}
+}
+
+func (e *FXAA) Apply(ctx *PostContext) *ColorBuffer {
+	src := ctx.Color
+	dst := NewColorBuffer(src.Width, src.Height)
+
+	luma := func(c Color) float64 {
+		return math.Sqrt(Clamp(luminance(c), 0, 1))
+	}
+
+	for y := 0; y < src.Height; y++ {
+		for x := 0; x < src.Width; x++ {
+			px, py := float64(x)+0.5, float64(y)+0.5
+			lumaNW := luma(src.Sample(px-1, py-1))
+			lumaNE := luma(src.Sample(px+1, py-1))
+			lumaSW := luma(src.Sample(px-1, py+1))
+			lumaSE := luma(src.Sample(px+1, py+1))
+			center := src.Pix[x+y*src.Width]
+			lumaM := luma(center)
+
+			lumaMin := math.Min(lumaM, math.Min(math.Min(lumaNW, lumaNE), math.Min(lumaSW, lumaSE)))
+			lumaMax := math.Max(lumaM, math.Max(math.Max(lumaNW, lumaNE), math.Max(lumaSW, lumaSE)))
+
+			dirX := -((lumaNW + lumaNE) - (lumaSW + lumaSE))
+			dirY := (lumaNW + lumaSW) - (lumaNE + lumaSE)
+			reduce := math.Max((lumaNW+lumaNE+lumaSW+lumaSE)*0.25*e.ReduceMul, e.ReduceMin)
+			rcpDirMin := 1 / (math.Min(math.Abs(dirX), math.Abs(dirY)) + reduce)
+			dirX = Clamp(dirX*rcpDirMin, -e.SpanMax, e.SpanMax)
+			dirY = Clamp(dirY*rcpDirMin, -e.SpanMax, e.SpanMax)
+
+			a := src.Sample(px+dirX*(1.0/3-0.5), py+dirY*(1.0/3-0.5)).
+				Lerp(src.Sample(px+dirX*(2.0/3-0.5), py+dirY*(2.0/3-0.5)), 0.5)
+			b := a.Scale(0.5).Add(src.Sample(px-dirX*0.5, py-dirY*0.5).
+				Lerp(src.Sample(px+dirX*0.5, py+dirY*0.5), 0.5).Scale(0.5))
+
+			c := b
+			if lumaB := luma(b); lumaB < lumaMin || lumaB > lumaMax {
+				c = a
+			}
+			c.A = center.A
+			dst.Pix[x+y*src.Width] = c
+		}
+	}
+
+	return dst
+}
+
+type Bloom struct {
+	Threshold float64
+	Intensity float64
+	Radius    int
+}
+
+func NewBloom(threshold, intensity float64, radius int) *Bloom {
+	return &Bloom{
+		Threshold: threshold,
+		Intensity: intensity,
+		Radius:    radius,
+	}
+}
+
+func (e *Bloom) Apply(ctx *PostContext) *ColorBuffer {
+	src := ctx.Color
+	bright := NewColorBuffer(src.Width, src.Height)
+	for i, c := range src.Pix {
+		l := luminance(c)
+		if l > e.Threshold {
+			bright.Pix[i] = c.MulScalar((l - e.Threshold) / l)
+		}
+	}
+
+	blurred := blur(bright, e.Radius)
+	dst := NewColorBuffer(src.Width, src.Height)
+	for i, c := range src.Pix {
+		b := blurred.Pix[i]
+		dst.Pix[i] = Color{
+			c.R + b.R*e.Intensity,
+			c.G + b.G*e.Intensity,
+			c.B + b.B*e.Intensity,
+			c.A,
+		}
+	}
+	return dst
+}
+
+type SSAO struct {
+	Radius   float64
+	Samples  int
+	Bias     float64
+	Strength float64
+}
+
+func NewSSAO(radius float64, samples int) *SSAO {
+	return &SSAO{
+		Radius:   radius,
+		Samples:  samples,
+		Bias:     0.025,
+		Strength: 1,
+	}
+}
+
+// Samplesが0以下のときは遮蔽を計算せず、入力をそのまま返す
+func (e *SSAO) Apply(ctx *PostContext) *ColorBuffer {
+	src := ctx.Color
+	w, h := src.Width, src.Height
+	if e.Samples <= 0 {
+		return src
+	}
+
+	// 半球内のサンプル点(中心付近に多く分布させる)
+	r := rand.New(rand.NewSource(1))
+	kernel := make([]Vector3, e.Samples)
+	for i := range kernel {
+		v := NewVector3(r.Float64()*2-1, r.Float64()*2-1, r.Float64()).Normalize()
+		s := float64(i) / float64(e.Samples)
+		kernel[i] = v.MulScalar(Interpolate(0.1, 1, s*s) * r.Float64())
+	}
+
+	occlusion := NewColorBuffer(w, h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			occlusion.Pix[x+y*w] = WHITE
+			if !ctx.HasGeometry(x, y) {
+				continue
+			}
+
+			p := ctx.ViewPosition(x, y)
+			n := e.normal(ctx, x, y, p)
+			if n == Zero() {
+				continue
+			}
+
+			// 画素ごとに回転させたTBN基底を作る
+			angle := float64((x%4)+(y%4)*4) / 16 * 2 * math.Pi
+			rv := NewVector3(math.Cos(angle), math.Sin(angle), 0)
+			t := rv.Sub(n.MulScalar(rv.Dot(n)))
+			if t.LengthSq() < 1e-8 {
+				t = UnitX().Sub(n.MulScalar(n.X))
+			}
+			t = t.Normalize()
+			b := n.Cross(t)
+
+			occluded := 0.0
+			for _, k := range kernel {
+				sp := p.Add(t.MulScalar(k.X).Add(b.MulScalar(k.Y)).Add(n.MulScalar(k.Z)).MulScalar(e.Radius))
+				sx, sy := ctx.Project(sp)
+				ix, iy := int(sx), int(sy)
+				if ix < 0 || iy < 0 || ix >= w || iy >= h || !ctx.HasGeometry(ix, iy) {
+					continue
+				}
+
+				sampleDepth := ctx.ViewDepth(ix, iy)
+				rangeCheck := Clamp(e.Radius/math.Abs(-p.Z-sampleDepth), 0, 1)
+				if sampleDepth <= -sp.Z-e.Bias {
+					occluded += rangeCheck
+				}
+			}
+
+			ao := 1 - occluded/float64(len(kernel))*e.Strength
+			ao = Clamp(ao, 0, 1)
+			occlusion.Pix[x+y*w] = NewColor(ao, ao, ao, 1)
+		}
+	}
+
+	occlusion = blur(occlusion, 2)
+	dst := NewColorBuffer(w, h)
+	for i, c := range src.Pix {
+		dst.Pix[i] = c.MulScalar(occlusion.Pix[i].R)
+	}
+	return dst
+}
+
+// 深度から隣接画素の位置を復元して法線を求める
+func (e *SSAO) normal(ctx *PostContext, x, y int, p Vector3) Vector3 {
+	neighbor := func(dx, dy int) (Vector3, bool) {
+		nx, ny := x+dx, y+dy
+		if nx < 0 || ny < 0 || nx >= ctx.Depth.Width || ny >= ctx.Depth.Height || !ctx.HasGeometry(nx, ny) {
+			return Zero(), false
+		}
+		return ctx.ViewPosition(nx, ny), true
+	}
+
+	pick := func(a, b Vector3, okA, okB bool) (Vector3, bool) {
+		switch {
+		case okA && okB:
+			if math.Abs(a.Z-p.Z) < math.Abs(b.Z-p.Z) {
+				return p.Sub(a), true
+			}
+			return b.Sub(p), true
+		case okA:
+			return p.Sub(a), true
+		case okB:
+			return b.Sub(p), true
+		}
+		return Zero(), false
+	}
+
+	l, okL := neighbor(-1, 0)
+	r, okR := neighbor(1, 0)
+	u, okU := neighbor(0, -1)
+	d, okD := neighbor(0, 1)
+	dx, okX := pick(l, r, okL, okR)
+	dy, okY := pick(u, d, okU, okD)
+	if !okX || !okY {
+		return Zero()
+	}
+
+	n := dy.Cross(dx)
+	if n.LengthSq() == 0 {
+		return Zero()
+	}
+	n = n.Normalize()
+	if n.Dot(p) > 0 {
+		n = n.Negate()
+	}
+	return n
+}
+
+type DepthOfField struct {
+	FocusDistance float64
+	Aperture      float64
+	MaxRadius     float64
+	Samples       int
+}
+
+// Apertureは無限遠での錯乱円の半径(ピクセル)
+func NewDepthOfField(focusDistance, aperture float64) *DepthOfField {
+	return &DepthOfField{
+		FocusDistance: focusDistance,
+		Aperture:      aperture,
+		MaxRadius:     16,
+		Samples:       32,
+	}
+}
+
+func (e *DepthOfField) coc(ctx *PostContext, x, y int) float64 {
+	z := ctx.ViewDepth(x, y)
+	if math.IsInf(z, 1) {
+		return e.MaxRadius
+	}
+	return math.Min(e.Aperture*math.Abs(z-e.FocusDistance)/z, e.MaxRadius)
+}
+
+func (e *DepthOfField) Apply(ctx *PostContext) *ColorBuffer {
+	src := ctx.Color
+	w, h := src.Width, src.Height
+
+	cocs := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			cocs[x+y*w] = e.coc(ctx, x, y)
+		}
+	}
+
+	// 黄金角で並べた円盤状のサンプル
+	offsets := make([][2]float64, e.Samples)
+	golden := math.Pi * (3 - math.Sqrt(5))
+	for i := range offsets {
+		r := math.Sqrt((float64(i) + 0.5) / float64(e.Samples))
+		theta := float64(i) * golden
+		offsets[i] = [2]float64{r * math.Cos(theta), r * math.Sin(theta)}
+	}
+
+	dst := NewColorBuffer(w, h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			center := src.Pix[x+y*w]
+			radius := cocs[x+y*w]
+			if radius < 0.5 {
+				dst.Pix[x+y*w] = center
+				continue
+			}
+
+			sum := center
+			weight := 1.0
+			for _, o := range offsets {
+				sx := x + int(o[0]*radius)
+				sy := y + int(o[1]*radius)
+				if sx < 0 || sy < 0 || sx >= w || sy >= h {
+					continue
+				}
+
+				// 手前のぼけていない画素が後ろに滲まないようにする
+				dist := math.Hypot(o[0]*radius, o[1]*radius)
+				if cocs[sx+sy*w] < dist && ctx.ViewDepth(sx, sy) < ctx.ViewDepth(x, y) {
+					continue
+				}
+				sum = sum.Add(src.Pix[sx+sy*w])
+				weight++
+			}
+			dst.Pix[x+y*w] = sum.Scale(1 / weight)
+		}
+	}
+	return dst
+}
+
+// Dataは赤が最も速く変わる順に並べる
+// 入力はDomainMinからDomainMaxの範囲を格子の端から端に対応させる
+type LUT3D struct {
+	Size int
+	Data []Color
+
+	DomainMin Vector3
+	DomainMax Vector3
+}
+
+func NewIdentityLUT(size int) *LUT3D {
+	l := &LUT3D{
+		Size:      size,
+		Data:      make([]Color, size*size*size),
+		DomainMin: Zero(),
+		DomainMax: Unit(),
+	}
+
+	f := float64(size - 1)
+	for b := 0; b < size; b++ {
+		for g := 0; g < size; g++ {
+			for r := 0; r < size; r++ {
+				l.Data[r+g*size+b*size*size] = NewColor(float64(r)/f, float64(g)/f, float64(b)/f, 1)
+			}
+		}
+	}
+	return l
+}
+
+// Adobe/Resolveの.cube形式の3D LUTを読み込む。1D LUTには対応しない
+func LoadCubeLUT(filename string) *LUT3D {
+	f, err := os.Open(filename)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+
+	return parseCubeLUT(f)
+}
+
+func parseCubeLUT(r io.Reader) *LUT3D {
+	l := &LUT3D{
+		DomainMin: Zero(),
+		DomainMax: Unit(),
+	}
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		cols := strings.Fields(s.Text())
+		if len(cols) == 0 || strings.HasPrefix(cols[0], "#") {
+			continue
+		}
+
+		switch cols[0] {
+		case "TITLE":
+			continue
+		case "LUT_3D_SIZE":
+			if len(cols) != 2 {
+				panic("cube: invalid LUT_3D_SIZE")
+			}
+			size, err := strconv.Atoi(cols[1])
+			if err != nil {
+				panic(err)
+			}
+			if size < 2 {
+				panic("cube: LUT_3D_SIZE must be at least 2")
+			}
+			l.Size = size
+			l.Data = make([]Color, 0, size*size*size)
+		case "LUT_1D_SIZE":
+			panic("cube: 1D LUTs are not supported")
+		case "DOMAIN_MIN":
+			l.DomainMin = parseCubeTriple(cols[1:])
+		case "DOMAIN_MAX":
+			l.DomainMax = parseCubeTriple(cols[1:])
+		default:
+			if l.Size == 0 {
+				panic("cube: table data before LUT_3D_SIZE")
+			}
+			if len(l.Data) == cap(l.Data) {
+				panic("cube: too many table entries")
+			}
+			l.Data = append(l.Data, NewColorFromVec(parseCubeTriple(cols)))
+		}
+	}
+	if err := s.Err(); err != nil {
+		panic(err)
+	}
+
+	if l.Size == 0 {
+		panic("cube: missing LUT_3D_SIZE")
+	}
+	if len(l.Data) != l.Size*l.Size*l.Size {
+		panic("cube: expected " + strconv.Itoa(l.Size*l.Size*l.Size) + " table entries, got " + strconv.Itoa(len(l.Data)))
+	}
+	if l.DomainMin.X >= l.DomainMax.X || l.DomainMin.Y >= l.DomainMax.Y || l.DomainMin.Z >= l.DomainMax.Z {
+		panic("cube: DOMAIN_MIN must be less than DOMAIN_MAX")
+	}
+	return l
+}
+
+func parseCubeTriple(cols []string) Vector3 {
+	if len(cols) != 3 {
+		panic("cube: expected 3 values, got " + strconv.Itoa(len(cols)))
+	}
+
+	var v [3]float64
+	for i, c := range cols {
+		f, err := strconv.ParseFloat(c, 64)
+		if err != nil {
+			panic(err)
+		}
+		v[i] = f
+	}
+	return NewVector3(v[0], v[1], v[2])
+}
+
+func (l *LUT3D) at(r, g, b int) Color {
+	return l.Data[r+g*l.Size+b*l.Size*l.Size]
+}
+
+// 範囲が設定されていなければ0から1とみなす
+func (l *LUT3D) coord(v, lo, hi float64) float64 {
+	if hi > lo {
+		v = (v - lo) / (hi - lo)
+	}
+	return Clamp(v, 0, 1) * float64(l.Size-1)
+}
+
+func (l *LUT3D) Lookup(c Color) Color {
+	r := l.coord(c.R, l.DomainMin.X, l.DomainMax.X)
+	g := l.coord(c.G, l.DomainMin.Y, l.DomainMax.Y)
+	b := l.coord(c.B, l.DomainMin.Z, l.DomainMax.Z)
+	r0, g0, b0 := int(r), int(g), int(b)
+	r1, g1, b1 := Min(r0+1, l.Size-1), Min(g0+1, l.Size-1), Min(b0+1, l.Size-1)
+	fr, fg, fb := r-float64(r0), g-float64(g0), b-float64(b0)
+
+	c00 := l.at(r0, g0, b0).Lerp(l.at(r1, g0, b0), fr)
+	c10 := l.at(r0, g1, b0).Lerp(l.at(r1, g1, b0), fr)
+	c01 := l.at(r0, g0, b1).Lerp(l.at(r1, g0, b1), fr)
+	c11 := l.at(r0, g1, b1).Lerp(l.at(r1, g1, b1), fr)
+	res := c00.Lerp(c10, fg).Lerp(c01.Lerp(c11, fg), fb)
+	res.A = c.A
+	return res
+}
+
+// HDRの値を残したまま3D LUTで色を調整する
+// LUTの入力と出力は、0からRangeまでの線形の値をlog2(1+x)/log2(1+Range)で0から1に収めたもの(シェーパー)
+// 暗部ほど格子が細かくなり、恒等LUTなら値は変わらない。Rangeを超える値はRangeに切り詰める
+type ColorGrading struct {
+	LUT   *LUT3D
+	Range float64
+}
+
+func NewColorGrading(lut *LUT3D) *ColorGrading {
+	return &ColorGrading{LUT: lut, Range: 64}
+}
+
+func (e *ColorGrading) Apply(ctx *PostContext) *ColorBuffer {
+	scale := math.Log2(1 + e.Range)
+	encode := func(x float64) float64 {
+		return math.Log2(1+Clamp(x, 0, e.Range)) / scale
+	}
+	decode := func(x float64) float64 {
+		return math.Exp2(x*scale) - 1
+	}
+
+	src := ctx.Color
+	dst := NewColorBuffer(src.Width, src.Height)
+	for i, c := range src.Pix {
+		g := e.LUT.Lookup(NewColor(encode(c.R), encode(c.G), encode(c.B), c.A))
+		dst.Pix[i] = NewColor(decode(g.R), decode(g.G), decode(g.B), c.A)
+	}
+	return dst
+}
+
+type Vignette struct {
+	Intensity float64
+	Radius    float64
+	Softness  float64
+}
+
+func NewVignette(intensity, radius, softness float64) *Vignette {
+	return &Vignette{
+		Intensity: intensity,
+		Radius:    radius,
+		Softness:  softness,
+	}
+}
+
+func (e *Vignette) Apply(ctx *PostContext) *ColorBuffer {
+	src := ctx.Color
+	dst := NewColorBuffer(src.Width, src.Height)
+	cx, cy := float64(src.Width)/2, float64(src.Height)/2
+	diag := math.Hypot(cx, cy)
+
+	for y := 0; y < src.Height; y++ {
+		for x := 0; x < src.Width; x++ {
+			dist := math.Hypot(float64(x)+0.5-cx, float64(y)+0.5-cy) / diag
+			t := Clamp((dist-e.Radius)/math.Max(e.Softness, 1e-6), 0, 1)
+			t = t * t * (3 - 2*t)
+			dst.Pix[x+y*src.Width] = src.Pix[x+y*src.Width].MulScalar(1 - e.Intensity*t)
+		}
+	}
+	return dst
+}
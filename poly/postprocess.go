@@ -0,0 +1,127 @@
+package poly
+
+import (
+	"math"
+
+	. "github.com/arata-nvm/poly/vecmath"
+)
+
+type PostEffect interface {
+	Apply(*PostContext) *ColorBuffer
+}
+
+type PostContext struct {
+	Color *ColorBuffer
+	Depth *DepthBuffer
+
+	Projection Matrix4
+
+	device *Device
+}
+
+func (d *Device) PostProcess(effects ...PostEffect) {
+	fb := d.framebuffer
+	if len(fb.ColorAttachments) == 0 {
+		return
+	}
+
+	ctx := &PostContext{
+		Color:      fb.ColorAttachments[0],
+		Depth:      fb.Depth,
+		Projection: d.projectionMatrix,
+		device:     d,
+	}
+
+	for _, e := range effects {
+		ctx.Color = e.Apply(ctx)
+	}
+
+	copy(fb.ColorAttachments[0].Pix, ctx.Color.Pix)
+}
+
+func (ctx *PostContext) HasGeometry(x, y int) bool {
+	z := ctx.Depth.Get(x, y)
+	return z != math.MaxFloat64 && z != -math.MaxFloat64
+}
+
+// カメラからの距離(ビュー空間での-z)を返す
+func (ctx *PostContext) ViewDepth(x, y int) float64 {
+	if !ctx.HasGeometry(x, y) {
+		return math.Inf(1)
+	}
+
+	p := ctx.Projection
+	z := ctx.device.ndcDepth(ctx.Depth.Get(x, y))
+	if p.M32 == 0 {
+		return -(z - p.M23) / p.M22
+	}
+	return p.M23 / (z + p.M22)
+}
+
+func (ctx *PostContext) ViewPosition(x, y int) Vector3 {
+	sx, sy := float64(x)+0.5, float64(ctx.Depth.Height-y)-0.5
+	nx, ny := ctx.device.screenToNDC(sx, sy)
+	zv := -ctx.ViewDepth(x, y)
+
+	p := ctx.Projection
+	if p.M32 == 0 {
+		return NewVector3((nx-p.M03)/p.M00, (ny-p.M13)/p.M11, zv)
+	}
+	return NewVector3((-nx*zv-p.M02*zv)/p.M00, (-ny*zv-p.M12*zv)/p.M11, zv)
+}
+
+// ビュー空間の点をバッファ上の座標に射影する
+func (ctx *PostContext) Project(v Vector3) (float64, float64) {
+	n := TransformCoordinate(v, ctx.Projection)
+	sx, sy := ctx.device.ndcToScreen(n.X, n.Y)
+	return sx, float64(ctx.Depth.Height) - sy
+}
+
+func luminance(c Color) float64 {
+	return 0.2126*c.R + 0.7152*c.G + 0.0722*c.B
+}
+
+func gaussianKernel(radius int) []float64 {
+	sigma := math.Max(float64(radius)/2, 0.5)
+	kernel := make([]float64, 2*radius+1)
+	sum := 0.0
+	for i := range kernel {
+		x := float64(i - radius)
+		kernel[i] = math.Exp(-x * x / (2 * sigma * sigma))
+		sum += kernel[i]
+	}
+	for i := range kernel {
+		kernel[i] /= sum
+	}
+	return kernel
+}
+
+func blur(src *ColorBuffer, radius int) *ColorBuffer {
+	kernel := gaussianKernel(radius)
+	tmp := NewColorBuffer(src.Width, src.Height)
+	dst := NewColorBuffer(src.Width, src.Height)
+
+	for y := 0; y < src.Height; y++ {
+		for x := 0; x < src.Width; x++ {
+			var c Color
+			for i, k := range kernel {
+				sx := Min(Max(x+i-radius, 0), src.Width-1)
+				c = c.Add(src.Pix[sx+y*src.Width].Scale(k))
+			}
+			tmp.Pix[x+y*src.Width] = c
+		}
+	}
+
+	for y := 0; y < src.Height; y++ {
+		for x := 0; x < src.Width; x++ {
+			var c Color
+			for i, k := range kernel {
+				sy := Min(Max(y+i-radius, 0), src.Height-1)
+				c = c.Add(tmp.Pix[x+sy*src.Width].Scale(k))
+			}
+			dst.Pix[x+y*src.Width] = c
+		}
+	}
+
+	return dst
+}
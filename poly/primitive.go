@@ -16,29 +16,29 @@ func NewPlane() *Mesh {
 	m := &Mesh{
 		Faces:    []*Face{
 			{
-				Vertex{
+				V1: Vertex{
 					Coordinates: v2,
 					Uv:          t1,
 				},
-				Vertex{
+				V2: Vertex{
 					Coordinates: v3,
 					Uv:          t2,
 				},
-				Vertex{
+				V3: Vertex{
 					Coordinates: v1,
 					Uv:          t3,
 				},
 			},
 			{
-				Vertex{
+				V1: Vertex{
 					Coordinates: v2,
 					Uv:          t1,
 				},
-				Vertex{
+				V2: Vertex{
 					Coordinates: v4,
 					Uv:          t4,
 				},
-				Vertex{
+				V3: Vertex{
 					Coordinates: v3,
 					Uv:          t2,
 				},
@@ -0,0 +1,104 @@
+package poly
+
+import (
+	"math"
+
+	. "github.com/arata-nvm/poly/vecmath"
+)
+
+type RayHit struct {
+	Node *Node
+	Mesh *Mesh
+	Face int
+
+	// V1, V2, V3の重み(InterpolateVertexにそのまま渡せる)
+	Barycentric Vector3
+	Distance    float64
+
+	// 座標・法線・UVはモデル座標系で補間し、Worldにワールド座標系の交点を入れる
+	Vertex Vertex
+}
+
+// 光線はワールド座標系で与える
+func (m *Mesh) Raycast(r Ray) (RayHit, bool) {
+	return m.raycast(r, m.ModelMatrix())
+}
+
+func (m *Mesh) raycast(r Ray, model Matrix4) (RayHit, bool) {
+	inv, ok := model.Inverse()
+	if !ok {
+		return RayHit{}, false
+	}
+	local := r.Transform(inv)
+
+	hit := RayHit{Mesh: m, Face: -1}
+	best := math.Inf(1)
+	test := func(i int) {
+		f := m.Faces[i]
+		t, u, v, ok := local.IntersectTriangle(f.V1.Coordinates, f.V2.Coordinates, f.V3.Coordinates)
+		if !ok || t >= best {
+			return
+		}
+		best = t
+		hit.Face = i
+		hit.Barycentric = NewVector3(1-u-v, u, v)
+	}
+
+	if m.bvh != nil {
+		// それまでに見つかった交点より遠い節は辿らない
+		m.bvh.Query(func(b AABB) bool {
+			tmin, _, ok := local.IntersectAABB(b)
+			return ok && tmin < best
+		}, test)
+	} else if _, _, ok := local.IntersectAABB(m.BoundingBox()); ok {
+		for i := range m.Faces {
+			test(i)
+		}
+	}
+
+	if hit.Face < 0 {
+		return RayHit{}, false
+	}
+
+	f := m.Faces[hit.Face]
+	hit.Distance = best * r.Direction.Length()
+	hit.Vertex = InterpolateVertex(f.V1, f.V2, f.V3, hit.Barycentric)
+	hit.Vertex.World = r.At(best)
+	return hit, true
+}
+
+// 最も近い交点を返す
+// 視錐台カリングと同じワールド座標の箱のBVHを作り、光線が通る箱のメッシュだけを調べる
+func (s *Scene) Raycast(r Ray) (RayHit, bool) {
+	items := s.instances()
+	length := r.Direction.Length()
+
+	var nearest RayHit
+	found := false
+	// それまでに見つかった交点より遠い節は辿らない
+	s.buildBVH(items).Query(func(b AABB) bool {
+		tmin, _, ok := r.IntersectAABB(b)
+		return ok && (!found || tmin*length < nearest.Distance)
+	}, func(i int) {
+		it := items[i]
+		hit, ok := it.mesh.raycast(r, it.model)
+		if !ok || (found && hit.Distance >= nearest.Distance) {
+			return
+		}
+		hit.Node = it.node
+		nearest, found = hit, true
+	})
+	return nearest, found
+}
+
+// 画像上の画素(左上原点、Imageと同じ)の中心を通る光線を返す
+func (d *Device) Unproject(x, y int) Ray {
+	sx, sy := float64(x)+0.5, float64(d.framebuffer.Height-y)-0.5
+	nx, ny := d.screenToNDC(sx, sy)
+	return unproject(nx, ny, d.projectionMatrix.Mul(d.viewMatrix))
+}
+
+// 画素に映っている面を返す
+func (d *Device) Pick(s *Scene, x, y int) (RayHit, bool) {
+	return s.Raycast(d.Unproject(x, y))
+}
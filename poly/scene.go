@@ -0,0 +1,271 @@
+package poly
+
+import . "github.com/arata-nvm/poly/vecmath"
+
+type Scene struct {
+	Root   *Node
+	Camera *Node
+
+	// 最後に振ったノードのID
+	lastID uint32
+}
+
+func NewScene() *Scene {
+	return &Scene{
+		Root: NewNode("root"),
+	}
+}
+
+type Node struct {
+	Name string
+	// DrawSceneでオブジェクトIDバッファに書き込まれる
+	// 0のノードにはScene.AssignIDsがシーンごとに1から順に値を振る。自分で設定してもよいが、重複は避けること
+	ID uint32
+
+	Meshes []*Mesh
+	Lights []Light
+	Camera *Camera
+
+	parent   *Node
+	children []*Node
+
+	transform Transform
+
+	local Matrix4
+	world Matrix4
+	dirty bool
+}
+
+func NewNode(name string) *Node {
+	return &Node{
+		Name:      name,
+		transform: IdentityTransform(),
+		local:     Identity(),
+		world:     Identity(),
+	}
+}
+
+func (n *Node) Parent() *Node {
+	return n.parent
+}
+
+func (n *Node) Children() []*Node {
+	return n.children
+}
+
+func (n *Node) AddChild(c *Node) {
+	if c.parent != nil {
+		c.parent.RemoveChild(c)
+	}
+	c.parent = n
+	n.children = append(n.children, c)
+	c.markDirty()
+}
+
+func (n *Node) RemoveChild(c *Node) {
+	for i, child := range n.children {
+		if child == c {
+			n.children = append(n.children[:i], n.children[i+1:]...)
+			c.parent = nil
+			c.markDirty()
+			return
+		}
+	}
+}
+
+func (n *Node) AddMesh(m *Mesh) {
+	n.Meshes = append(n.Meshes, m)
+}
+
+func (n *Node) AddLight(l Light) {
+	n.Lights = append(n.Lights, l)
+}
+
+func (n *Node) Transform() Transform {
+	return n.transform
+}
+
+func (n *Node) SetTransform(t Transform) {
+	n.transform = t
+	n.updateLocal()
+}
+
+func (n *Node) Position() Vector3 {
+	return n.transform.Translation
+}
+
+func (n *Node) Rotation() Quaternion {
+	return n.transform.Rotation
+}
+
+func (n *Node) Scale() Vector3 {
+	return n.transform.Scale
+}
+
+func (n *Node) SetPosition(v Vector3) {
+	n.transform.Translation = v
+	n.updateLocal()
+}
+
+func (n *Node) SetRotation(q Quaternion) {
+	n.transform.Rotation = q
+	n.updateLocal()
+}
+
+func (n *Node) SetScale(v Vector3) {
+	n.transform.Scale = v
+	n.updateLocal()
+}
+
+func (n *Node) LocalMatrix() Matrix4 {
+	return n.local
+}
+
+func (n *Node) WorldMatrix() Matrix4 {
+	if n.dirty {
+		if n.parent != nil {
+			n.world = n.parent.WorldMatrix().Mul(n.local)
+		} else {
+			n.world = n.local
+		}
+		n.dirty = false
+	}
+	return n.world
+}
+
+func (n *Node) Walk(f func(*Node)) {
+	f(n)
+	for _, c := range n.children {
+		c.Walk(f)
+	}
+}
+
+func (n *Node) updateLocal() {
+	n.local = n.transform.Matrix()
+	n.markDirty()
+}
+
+// 子孫のワールド行列もすべて無効にする
+// ノードが汚れていれば子孫も汚れているので、そこで打ち切ってよい
+func (n *Node) markDirty() {
+	if n.dirty {
+		return
+	}
+	n.dirty = true
+	for _, c := range n.children {
+		c.markDirty()
+	}
+}
+
+// IDが0のノードに、Rootからたどった順に未使用のIDを振る
+// 振ったIDは変わらないので、後から加えたノードには続きの値が振られる
+// DrawSceneが描画の前に呼ぶ
+func (s *Scene) AssignIDs() {
+	s.Root.Walk(func(n *Node) {
+		if n.ID != NoID && n.ID > s.lastID {
+			s.lastID = n.ID
+		}
+	})
+	s.Root.Walk(func(n *Node) {
+		if n.ID != 0 {
+			return
+		}
+		s.lastID++
+		n.ID = s.lastID
+	})
+}
+
+func (s *Scene) WorldCamera() (Camera, bool) {
+	if s.Camera == nil || s.Camera.Camera == nil {
+		return Camera{}, false
+	}
+
+	c := *s.Camera.Camera
+	m := s.Camera.WorldMatrix()
+	c.Position = m.MulVector(c.Position)
+	c.Target = m.MulVector(c.Target)
+	c.Up = TransformDirection(c.Up, m)
+	return c, true
+}
+
+func (s *Scene) WorldLights() []Light {
+	lights := make([]Light, 0)
+	s.Root.Walk(func(n *Node) {
+		m := n.WorldMatrix()
+		for _, l := range n.Lights {
+			lights = append(lights, transformLight(l, m))
+		}
+	})
+	return lights
+}
+
+func transformLight(l Light, m Matrix4) Light {
+	switch l := l.(type) {
+	case *DirectionalLight:
+		dir := TransformDirection(l.Direction, m)
+		return NewDirectionalLight(dir, l.Color)
+	case *PointLight:
+		return NewPointLight(m.MulVector(l.Position), l.Color, l.Intensity)
+	default:
+		return l
+	}
+}
+
+func (d *Device) DrawScene(s *Scene) {
+	s.AssignIDs()
+
+	if c, ok := s.WorldCamera(); ok {
+		d.SetCamera(c)
+	}
+
+	if ls, ok := d.shader.(LightShader); ok {
+		ls.SetLights(s.WorldLights(), d.camera.Position)
+	}
+
+	objectID := d.objectID
+	defer d.SetObjectID(objectID)
+
+	items := s.instances()
+	if !d.frustumCulling {
+		for _, it := range items {
+			d.drawInstance(it)
+		}
+		return
+	}
+
+	frustum := FrustumFromMatrix(d.projectionMatrix.Mul(d.viewMatrix))
+	s.buildBVH(items).Query(frustum.IntersectsAABB, func(i int) {
+		d.drawInstance(items[i])
+	})
+}
+
+func (d *Device) drawInstance(it meshInstance) {
+	d.SetObjectID(it.node.ID)
+	d.drawMesh(it.mesh, it.model)
+}
+
+type meshInstance struct {
+	node  *Node
+	mesh  *Mesh
+	model Matrix4
+}
+
+func (s *Scene) instances() []meshInstance {
+	items := make([]meshInstance, 0)
+	s.Root.Walk(func(n *Node) {
+		world := n.WorldMatrix()
+		for _, m := range n.Meshes {
+			items = append(items, meshInstance{n, m, world.Mul(m.ModelMatrix())})
+		}
+	})
+	return items
+}
+
+// ノードは毎回動き得るので、描画のたびにワールド座標の箱から作り直す
+func (s *Scene) buildBVH(items []meshInstance) *BVH {
+	bounds := make([]AABB, len(items))
+	for i, it := range items {
+		bounds[i] = it.mesh.localBounds().Transform(it.model)
+	}
+	return NewBVH(bounds)
+}
@@ -0,0 +1,114 @@
+package poly
+
+import (
+	"math"
+
+	. "github.com/arata-nvm/poly/vecmath"
+)
+
+// 符号付き距離関数(内側が負)による基本形状と、その組み合わせ
+// MarchingCubesにiso = 0で渡してメッシュにする
+
+func SphereSDF(center Vector3, radius float64) FieldFunc {
+	return func(p Vector3) float64 {
+		return p.Sub(center).Length() - radius
+	}
+}
+
+// halfSizeは各軸の中心から面までの距離
+func BoxSDF(center, halfSize Vector3) FieldFunc {
+	return func(p Vector3) float64 {
+		q := p.Sub(center)
+		d := NewVector3(math.Abs(q.X)-halfSize.X, math.Abs(q.Y)-halfSize.Y, math.Abs(q.Z)-halfSize.Z)
+		outside := NewVector3(math.Max(d.X, 0), math.Max(d.Y, 0), math.Max(d.Z, 0)).Length()
+		inside := math.Min(math.Max(d.X, math.Max(d.Y, d.Z)), 0)
+		return outside + inside
+	}
+}
+
+// Y軸を中心とするトーラス
+func TorusSDF(center Vector3, majorRadius, minorRadius float64) FieldFunc {
+	return func(p Vector3) float64 {
+		q := p.Sub(center)
+		ring := math.Hypot(q.X, q.Z) - majorRadius
+		return math.Hypot(ring, q.Y) - minorRadius
+	}
+}
+
+// Y軸に沿った、高さ2*halfHeightの円柱
+func CylinderSDF(center Vector3, radius, halfHeight float64) FieldFunc {
+	return func(p Vector3) float64 {
+		q := p.Sub(center)
+		dr := math.Hypot(q.X, q.Z) - radius
+		dy := math.Abs(q.Y) - halfHeight
+		return math.Min(math.Max(dr, dy), 0) + math.Hypot(math.Max(dr, 0), math.Max(dy, 0))
+	}
+}
+
+// 線分abから距離radius以内の領域
+func CapsuleSDF(a, b Vector3, radius float64) FieldFunc {
+	return func(p Vector3) float64 {
+		ab := b.Sub(a)
+		t := 0.0
+		if l := ab.LengthSq(); l > 0 {
+			t = Clamp(p.Sub(a).Dot(ab)/l, 0, 1)
+		}
+		return p.Sub(a.Add(ab.MulScalar(t))).Length() - radius
+	}
+}
+
+// 法線normalの向きが外側になる、点pointを通る平面
+func PlaneSDF(point, normal Vector3) FieldFunc {
+	n := normal.Normalize()
+	return func(p Vector3) float64 {
+		return p.Sub(point).Dot(n)
+	}
+}
+
+func UnionSDF(fields ...ScalarField) FieldFunc {
+	return func(p Vector3) float64 {
+		d := math.Inf(1)
+		for _, f := range fields {
+			d = math.Min(d, f.Value(p))
+		}
+		return d
+	}
+}
+
+func IntersectSDF(fields ...ScalarField) FieldFunc {
+	return func(p Vector3) float64 {
+		d := math.Inf(-1)
+		for _, f := range fields {
+			d = math.Max(d, f.Value(p))
+		}
+		return d
+	}
+}
+
+// aからbの内側を取り除く
+func SubtractSDF(a, b ScalarField) FieldFunc {
+	return func(p Vector3) float64 {
+		return math.Max(a.Value(p), -b.Value(p))
+	}
+}
+
+// 継ぎ目をkの幅で滑らかにつなぐ和集合(多項式スムースmin)
+func SmoothUnionSDF(a, b ScalarField, k float64) FieldFunc {
+	return func(p Vector3) float64 {
+		da, db := a.Value(p), b.Value(p)
+		if k <= 0 {
+			return math.Min(da, db)
+		}
+		h := Clamp(0.5+0.5*(db-da)/k, 0, 1)
+		return Interpolate(db, da, h) - k*h*(1-h)
+	}
+}
+
+// 場をtだけ動かす。非一様スケールでは距離が正確でなくなる
+func TransformSDF(field ScalarField, t Transform) FieldFunc {
+	inv := t.Inverse()
+	scale := math.Min(math.Abs(t.Scale.X), math.Min(math.Abs(t.Scale.Y), math.Abs(t.Scale.Z)))
+	return func(p Vector3) float64 {
+		return field.Value(inv.TransformPoint(p)) * scale
+	}
+}
@@ -11,6 +11,21 @@ type Shader interface {
 	Fragment(Vertex, Vector3) Color
 }
 
+type ModelShader interface {
+	Shader
+	SetModel(Matrix4)
+}
+
+type LightShader interface {
+	Shader
+	SetLights([]Light, Vector3)
+}
+
+type MultiTargetShader interface {
+	Shader
+	FragmentTargets(Vertex, Vector3, []Color)
+}
+
 type SolidShader struct {
 	Color Color
 }
@@ -72,6 +87,59 @@ func (s *TextureShader) Fragment(v Vertex, _ Vector3) Color {
 	return s.Texture.Map(v.Uv.X, v.Uv.Y)
 }
 
+// UVを使わず、ワールド座標をX, Y, Zの3方向から投影したテクスチャを法線の向きで混ぜる
+type TriplanarShader struct {
+	Texture *Texture
+	// ワールド座標の長さ1あたりのテクスチャの繰り返し回数
+	Scale float64
+	// 大きいほど投影の切り替わりが鋭くなる
+	Sharpness float64
+
+	model        Matrix4
+	normalMatrix Matrix3
+}
+
+func NewTriplanarShader(texture *Texture, scale float64) *TriplanarShader {
+	return &TriplanarShader{
+		Texture:      texture,
+		Scale:        scale,
+		Sharpness:    4,
+		model:        Identity(),
+		normalMatrix: Identity3(),
+	}
+}
+
+func (s *TriplanarShader) SetModel(m Matrix4) {
+	s.model = m
+	s.normalMatrix = m.NormalMatrix()
+}
+
+func (s *TriplanarShader) Vertex(v Vertex, m Matrix4) Vertex {
+	v.World = s.model.MulVector(v.Coordinates)
+	v.Normal = s.normalMatrix.MulVector(v.Normal).Normalize()
+	v.Coordinates = TransformCoordinate(v.Coordinates, m)
+	return v
+}
+
+func (s *TriplanarShader) Fragment(v Vertex, _ Vector3) Color {
+	n := v.Normal
+	w := NewVector3(
+		math.Pow(math.Abs(n.X), s.Sharpness),
+		math.Pow(math.Abs(n.Y), s.Sharpness),
+		math.Pow(math.Abs(n.Z), s.Sharpness),
+	)
+	total := w.X + w.Y + w.Z
+	if total == 0 {
+		w, total = Unit(), 3
+	}
+
+	p := v.World.MulScalar(s.Scale)
+	x := s.Texture.Map(p.Z, p.Y)
+	y := s.Texture.Map(p.X, -p.Z)
+	z := s.Texture.Map(p.X, p.Y)
+	return x.MulScalar(w.X / total).Add(y.MulScalar(w.Y / total)).Add(z.MulScalar(w.Z / total))
+}
+
 type NormalShader struct{}
 
 func NewNormalShader() *NormalShader {
@@ -127,3 +195,70 @@ func (s *PhongShader) Fragment(v Vertex, _ Vector3) Color {
 
 	return s.Color.Mul(c).Min(WHITE)
 }
+
+type LitShader struct {
+	Color     Color
+	Ambient   Color
+	Specular  float64
+	Shininess float64
+
+	lights       []Light
+	eye          Vector3
+	model        Matrix4
+	normalMatrix Matrix3
+}
+
+func NewLitShader(color Color, specular, shininess float64) *LitShader {
+	return &LitShader{
+		Color:        color,
+		Ambient:      NewColor(0.05, 0.05, 0.05, 1),
+		Specular:     specular,
+		Shininess:    shininess,
+		model:        Identity(),
+		normalMatrix: Identity3(),
+	}
+}
+
+func (s *LitShader) SetModel(m Matrix4) {
+	s.model = m
+	s.normalMatrix = m.NormalMatrix()
+}
+
+func (s *LitShader) SetLights(lights []Light, eye Vector3) {
+	s.lights = lights
+	s.eye = eye
+}
+
+func (s *LitShader) Vertex(v Vertex, m Matrix4) Vertex {
+	v.World = s.model.MulVector(v.Coordinates)
+	v.Normal = s.normalMatrix.MulVector(v.Normal).Normalize()
+	v.Coordinates = TransformCoordinate(v.Coordinates, m)
+	return v
+}
+
+func (s *LitShader) Fragment(v Vertex, _ Vector3) Color {
+	n := v.Normal.Normalize()
+	c := s.Color.Mul(s.Ambient).Add(shadeBlinnPhong(v.World, n, s.eye, s.Color, s.Specular, s.Shininess, s.lights))
+	c.A = s.Color.A
+	return c
+}
+
+// 位置posで法線nの点を、eyeから見たときのライトによる拡散光と鏡面反射光(Blinn-Phong)の和
+// 環境光は含まない
+func shadeBlinnPhong(pos, n, eye Vector3, albedo Color, specular, shininess float64, lights []Light) Color {
+	view := eye.Sub(pos).Normalize()
+
+	c := NewColor(0, 0, 0, 0)
+	for _, l := range lights {
+		dir, radiance := l.Illuminate(pos)
+		diffuse := n.Dot(dir)
+		if diffuse <= 0 {
+			continue
+		}
+
+		h := dir.Add(view).Normalize()
+		spec := specular * math.Pow(math.Max(n.Dot(h), 0), shininess)
+		c = c.Add(albedo.MulScalar(diffuse).Add(NewColor(spec, spec, spec, 0)).Mul(radiance))
+	}
+	return c
+}
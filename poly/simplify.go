@@ -0,0 +1,397 @@
+package poly
+
+import (
+	"container/heap"
+
+	. "github.com/arata-nvm/poly/vecmath"
+)
+
+// 境界やUVの継ぎ目を動かしにくくするための重み
+const seamPenalty = 1000
+
+// 4x4の対称行列を上三角の10要素で持つ
+type quadric [10]float64
+
+func planeQuadric(n Vector3, d, weight float64) quadric {
+	a, b, c := n.X, n.Y, n.Z
+	return quadric{
+		a * a * weight, a * b * weight, a * c * weight, a * d * weight,
+		b * b * weight, b * c * weight, b * d * weight,
+		c * c * weight, c * d * weight,
+		d * d * weight,
+	}
+}
+
+func (q quadric) add(r quadric) quadric {
+	for i := range q {
+		q[i] += r[i]
+	}
+	return q
+}
+
+func (q quadric) evaluate(p Vector3) float64 {
+	x, y, z := p.X, p.Y, p.Z
+	return q[0]*x*x + 2*q[1]*x*y + 2*q[2]*x*z + 2*q[3]*x +
+		q[4]*y*y + 2*q[5]*y*z + 2*q[6]*y +
+		q[7]*z*z + 2*q[8]*z +
+		q[9]
+}
+
+// 誤差が最小になる点を返す。行列が特異ならfalse
+func (q quadric) optimal() (Vector3, bool) {
+	a := Matrix3{
+		M00: q[0], M01: q[1], M02: q[2],
+		M10: q[1], M11: q[4], M12: q[5],
+		M20: q[2], M21: q[5], M22: q[7],
+	}
+	inv, ok := a.Inverse()
+	if !ok {
+		return Zero(), false
+	}
+	return inv.MulVector(NewVector3(-q[3], -q[6], -q[8])), true
+}
+
+type collapse struct {
+	cost   float64
+	u, v   int
+	target Vector3
+
+	versionU, versionV int
+}
+
+type collapseQueue []collapse
+
+func (q collapseQueue) Len() int            { return len(q) }
+func (q collapseQueue) Less(i, j int) bool  { return q[i].cost < q[j].cost }
+func (q collapseQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *collapseQueue) Push(x interface{}) { *q = append(*q, x.(collapse)) }
+func (q *collapseQueue) Pop() interface{} {
+	old := *q
+	c := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return c
+}
+
+type simplifier struct {
+	*indexedMesh
+
+	vertFaces [][]int
+	faceAlive []bool
+	vertAlive []bool
+	version   []int
+	quadrics  []quadric
+	queue     collapseQueue
+}
+
+// Garland–Heckbertの二次誤差による辺の縮約で、面の数をtargetFaces以下に減らす
+// 境界とUVの継ぎ目はできるだけ保ち、最後にスムージンググループごとに法線を計算し直す
+func (m *Mesh) Simplify(targetFaces int) {
+	if len(m.Faces) <= targetFaces {
+		return
+	}
+
+	s := newSimplifier(newIndexedMesh(m))
+	s.run(targetFaces)
+
+	m.Faces = s.result().toFaces()
+	m.Polygons = nil
+	m.recomputeNormals()
+	if m.bvh != nil {
+		m.BuildBVH()
+	}
+}
+
+// ratiosは元の面数に対する割合(1, 0.5, 0.25 など)
+// 各段は一つ前の段を簡略化して作る
+func (m *Mesh) LODChain(ratios ...float64) []*Mesh {
+	lods := make([]*Mesh, len(ratios))
+	prev := m
+	for i, r := range ratios {
+		target := int(float64(len(m.Faces)) * r)
+		if len(prev.Faces) < target {
+			prev = m
+		}
+		lod := prev.Clone()
+		lod.Simplify(target)
+		lods[i] = lod
+		prev = lod
+	}
+	return lods
+}
+
+func newSimplifier(im *indexedMesh) *simplifier {
+	s := &simplifier{
+		indexedMesh: im,
+		vertFaces:   im.vertexFaces(),
+		faceAlive:   make([]bool, len(im.faces)),
+		vertAlive:   make([]bool, len(im.positions)),
+		version:     make([]int, len(im.positions)),
+		quadrics:    make([]quadric, len(im.positions)),
+	}
+	for i := range s.faceAlive {
+		s.faceAlive[i] = true
+	}
+	for i := range s.vertAlive {
+		s.vertAlive[i] = true
+	}
+
+	for i, f := range im.faces {
+		n, ok := s.faceNormal(f)
+		if !ok {
+			continue
+		}
+		q := planeQuadric(n, -n.Dot(im.positions[f[0]]), 1)
+		for _, v := range f {
+			s.quadrics[v] = s.quadrics[v].add(q)
+		}
+
+		// 境界と継ぎ目の辺には、面に垂直な平面を重く加える
+		for k := 0; k < 3; k++ {
+			a, b := f[k], f[(k+1)%3]
+			if !s.isFeatureEdge(i, k) {
+				continue
+			}
+			pa, pb := im.positions[a], im.positions[b]
+			pn := pb.Sub(pa).Cross(n).Normalize()
+			c := planeQuadric(pn, -pn.Dot(pa), seamPenalty)
+			s.quadrics[a] = s.quadrics[a].add(c)
+			s.quadrics[b] = s.quadrics[b].add(c)
+		}
+	}
+
+	seen := make(map[[2]int]bool)
+	for _, f := range im.faces {
+		for k := 0; k < 3; k++ {
+			a, b := f[k], f[(k+1)%3]
+			if a > b {
+				a, b = b, a
+			}
+			if seen[[2]int{a, b}] {
+				continue
+			}
+			seen[[2]int{a, b}] = true
+			s.push(a, b)
+		}
+	}
+	heap.Init(&s.queue)
+	return s
+}
+
+// 面faceのk番目の辺が境界か、隣の面とUVが食い違っていればtrue
+func (s *simplifier) isFeatureEdge(face, k int) bool {
+	f := s.faces[face]
+	a, b := f[k], f[(k+1)%3]
+	uvA, uvB := s.corners[face][k].Uv, s.corners[face][(k+1)%3].Uv
+
+	for _, g := range s.vertFaces[a] {
+		if g == face {
+			continue
+		}
+		ka, kb := s.cornerOf(g, a), s.cornerOf(g, b)
+		if kb < 0 {
+			continue
+		}
+		return s.corners[g][ka].Uv != uvA || s.corners[g][kb].Uv != uvB
+	}
+	return true
+}
+
+func (s *simplifier) cornerOf(face, v int) int {
+	for k, w := range s.faces[face] {
+		if w == v {
+			return k
+		}
+	}
+	return -1
+}
+
+func (s *simplifier) faceNormal(f [3]int) (Vector3, bool) {
+	p := s.positions
+	n := p[f[1]].Sub(p[f[0]]).Cross(p[f[2]].Sub(p[f[0]]))
+	l := n.Length()
+	if l == 0 {
+		return Zero(), false
+	}
+	return n.DivScalar(l), true
+}
+
+func (s *simplifier) push(u, v int) {
+	q := s.quadrics[u].add(s.quadrics[v])
+	pu, pv := s.positions[u], s.positions[v]
+	mid := pu.Add(pv).MulScalar(0.5)
+
+	// 最適点が辺から大きく外れる場合は、端点と中点から選ぶ
+	target, ok := q.optimal()
+	if !ok || target.Sub(mid).Length() > pv.Sub(pu).Length() {
+		target = pu
+		for _, p := range []Vector3{pv, mid} {
+			if q.evaluate(p) < q.evaluate(target) {
+				target = p
+			}
+		}
+	}
+
+	s.queue = append(s.queue, collapse{
+		cost:     q.evaluate(target),
+		u:        u,
+		v:        v,
+		target:   target,
+		versionU: s.version[u],
+		versionV: s.version[v],
+	})
+}
+
+func (s *simplifier) run(targetFaces int) {
+	count := len(s.faces)
+	for count > targetFaces && s.queue.Len() > 0 {
+		c := heap.Pop(&s.queue).(collapse)
+		if !s.vertAlive[c.u] || !s.vertAlive[c.v] ||
+			s.version[c.u] != c.versionU || s.version[c.v] != c.versionV {
+			continue
+		}
+		if !s.canCollapse(c.u, c.v, c.target) {
+			continue
+		}
+		count -= s.collapse(c.u, c.v, c.target)
+
+		for _, w := range s.neighbors(c.u) {
+			s.push(c.u, w)
+			heap.Fix(&s.queue, s.queue.Len()-1)
+		}
+	}
+}
+
+func (s *simplifier) neighbors(v int) []int {
+	seen := make(map[int]bool)
+	var ns []int
+	for _, f := range s.vertFaces[v] {
+		if !s.faceAlive[f] {
+			continue
+		}
+		for _, w := range s.faces[f] {
+			if w != v && !seen[w] {
+				seen[w] = true
+				ns = append(ns, w)
+			}
+		}
+	}
+	return ns
+}
+
+// 多様体でなくなる縮約と、面が裏返る縮約を拒否する
+func (s *simplifier) canCollapse(u, v int, target Vector3) bool {
+	shared := 0
+	for _, f := range s.vertFaces[u] {
+		if s.faceAlive[f] && s.cornerOf(f, v) >= 0 {
+			shared++
+		}
+	}
+	if shared == 0 {
+		return false
+	}
+
+	nu := s.neighbors(u)
+	common := 0
+	for _, w := range s.neighbors(v) {
+		for _, x := range nu {
+			if w == x {
+				common++
+			}
+		}
+	}
+	if common != shared {
+		return false
+	}
+
+	for _, w := range [2]int{u, v} {
+		for _, f := range s.vertFaces[w] {
+			if !s.faceAlive[f] || (s.cornerOf(f, u) >= 0 && s.cornerOf(f, v) >= 0) {
+				continue
+			}
+			before, ok := s.faceNormal(s.faces[f])
+			if !ok {
+				continue
+			}
+
+			p := s.positions[w]
+			s.positions[w] = target
+			after, ok := s.faceNormal(s.faces[f])
+			s.positions[w] = p
+			if !ok || after.Dot(before) < 0.2 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// vをuにまとめ、取り除かれた面の数を返す
+func (s *simplifier) collapse(u, v int, target Vector3) int {
+	pu, pv := s.positions[u], s.positions[v]
+	t := 0.0
+	if e := pv.Sub(pu); e.LengthSq() > 0 {
+		t = Clamp(target.Sub(pu).Dot(e)/e.LengthSq(), 0, 1)
+	}
+	uvU, okU := s.uniqueUv(u)
+	uvV, okV := s.uniqueUv(v)
+
+	removed := 0
+	for _, f := range s.vertFaces[v] {
+		if !s.faceAlive[f] {
+			continue
+		}
+		if s.cornerOf(f, u) >= 0 {
+			s.faceAlive[f] = false
+			removed++
+			continue
+		}
+		s.faces[f][s.cornerOf(f, v)] = u
+		s.vertFaces[u] = append(s.vertFaces[u], f)
+	}
+
+	// 継ぎ目でなければUVも辺に沿って補間する
+	if okU && okV {
+		uv := uvU.MulScalar(1 - t).Add(uvV.MulScalar(t))
+		for _, f := range s.vertFaces[u] {
+			if s.faceAlive[f] {
+				s.corners[f][s.cornerOf(f, u)].Uv = uv
+			}
+		}
+	}
+
+	s.positions[u] = target
+	s.quadrics[u] = s.quadrics[u].add(s.quadrics[v])
+	s.vertAlive[v] = false
+	s.vertFaces[v] = nil
+	s.version[u]++
+	s.version[v]++
+	return removed
+}
+
+func (s *simplifier) uniqueUv(v int) (Vector3, bool) {
+	var uv Vector3
+	found := false
+	for _, f := range s.vertFaces[v] {
+		if !s.faceAlive[f] {
+			continue
+		}
+		c := s.corners[f][s.cornerOf(f, v)].Uv
+		if found && c != uv {
+			return Zero(), false
+		}
+		uv, found = c, true
+	}
+	return uv, found
+}
+
+func (s *simplifier) result() *indexedMesh {
+	im := &indexedMesh{positions: s.positions}
+	for i, f := range s.faces {
+		if s.faceAlive[i] {
+			im.faces = append(im.faces, f)
+			im.corners = append(im.corners, s.corners[i])
+			im.groups = append(im.groups, s.groups[i])
+		}
+	}
+	return im
+}
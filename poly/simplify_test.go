@@ -0,0 +1,74 @@
+package poly
+
+import (
+	"math"
+	"testing"
+
+	. "github.com/arata-nvm/poly/vecmath"
+)
+
+// 波打った地形のメッシュ。境界は一辺2の正方形になる
+func wavyTerrainMesh(n int) *Mesh {
+	h := NewHeightmap(n, n, nil)
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			h.Set(x, y, 0.1*math.Sin(float64(x)*0.7)*math.Cos(float64(y)*0.5))
+		}
+	}
+	return NewTerrain(h, NewVector3(2, 1, 2)).Mesh()
+}
+
+func TestSimplify(t *testing.T) {
+	cases := []struct {
+		name   string
+		mesh   func() *Mesh
+		target int
+		closed bool
+	}{
+		{"sphere", func() *Mesh {
+			return MarchingCubes(SphereSDF(Zero(), 1), AABB{Min: NewVector3(-1.5, -1.5, -1.5), Max: NewVector3(1.5, 1.5, 1.5)}, 16, 0)
+		}, 200, true},
+		{"terrain", func() *Mesh { return wavyTerrainMesh(17) }, 100, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := c.mesh()
+			before := m.Topology().BoundaryLoops()
+			bounds := m.BoundingBox()
+
+			m.Simplify(c.target)
+			if len(m.Faces) > c.target || len(m.Faces) < c.target-2 {
+				t.Fatalf("got %d faces, want %d or slightly fewer", len(m.Faces), c.target)
+			}
+
+			r := m.Validate()
+			if len(r.NonManifoldEdges) > 0 || len(r.InconsistentWinding) > 0 || len(r.DegenerateFaces) > 0 {
+				t.Fatalf("simplified mesh is broken: %+v", r)
+			}
+			if len(r.BoundaryLoops) != len(before) {
+				t.Fatalf("got %d boundary loops, want %d", len(r.BoundaryLoops), len(before))
+			}
+			if c.closed {
+				return
+			}
+
+			// 境界の頂点は元の正方形の縁からほとんど動かず、角も残る
+			// 縁に垂直な平面の二次誤差で抑えるだけなので、起伏があるとわずかにずれる
+			for _, loop := range r.BoundaryLoops {
+				for _, p := range loop {
+					onEdge := math.Abs(math.Abs(p.X)-1) < 1e-2 || math.Abs(math.Abs(p.Z)-1) < 1e-2
+					if !onEdge {
+						t.Fatalf("boundary vertex %v left the border", p)
+					}
+				}
+			}
+			got := m.BoundingBox()
+			for _, d := range []float64{got.Min.X - bounds.Min.X, got.Max.X - bounds.Max.X, got.Min.Z - bounds.Min.Z, got.Max.Z - bounds.Max.Z} {
+				if math.Abs(d) > 1e-2 {
+					t.Fatalf("bounds changed from %v to %v", bounds, got)
+				}
+			}
+		})
+	}
+}
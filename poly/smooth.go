@@ -0,0 +1,136 @@
+package poly
+
+import (
+	"math"
+
+	. "github.com/arata-nvm/poly/vecmath"
+)
+
+// ラプラシアンを求めるときの、隣の頂点の重み
+type LaplacianWeighting int
+
+const (
+	LaplacianUniform LaplacianWeighting = iota
+	// 辺の向かいの角の余接で重み付けする。三角形の形の偏りに強い
+	LaplacianCotangent
+)
+
+// 各頂点を隣の頂点の(重み付き)平均に向けてlambdaの割合だけ動かす
+// 繰り返すと全体が縮んでいくので、形を保ちたい場合はSmoothTaubinを使う
+// pinBoundaryがtrueなら境界の頂点は動かさない。最後にComputeNormalsと同じ設定で法線を計算し直す
+func (m *Mesh) SmoothLaplacian(weighting LaplacianWeighting, lambda float64, iterations int, pinBoundary bool) {
+	s := newLaplacianSmoother(m, weighting, pinBoundary)
+	for i := 0; i < iterations; i++ {
+		s.step(lambda)
+	}
+	s.apply(m)
+}
+
+// Taubinのλ|μ法。縮める向き(lambda > 0)と膨らませる向き(mu < -lambda)を交互に適用する
+// lambda = 0.5, mu = -0.53 程度がよく使われる
+func (m *Mesh) SmoothTaubin(weighting LaplacianWeighting, lambda, mu float64, iterations int, pinBoundary bool) {
+	s := newLaplacianSmoother(m, weighting, pinBoundary)
+	for i := 0; i < iterations; i++ {
+		s.step(lambda)
+		s.step(mu)
+	}
+	s.apply(m)
+}
+
+type laplacianSmoother struct {
+	positions []Vector3
+	faces     [][3]int
+
+	weighting LaplacianWeighting
+	neighbors [][]int
+	pinned    []bool
+}
+
+func newLaplacianSmoother(m *Mesh, weighting LaplacianWeighting, pinBoundary bool) *laplacianSmoother {
+	t := m.Topology()
+	s := &laplacianSmoother{
+		positions: t.Positions,
+		faces:     t.Faces,
+		weighting: weighting,
+		neighbors: make([][]int, len(t.Positions)),
+		pinned:    make([]bool, len(t.Positions)),
+	}
+
+	for v := range s.positions {
+		s.neighbors[v] = t.VertexNeighbors(v)
+	}
+
+	if pinBoundary {
+		for h, he := range t.HalfEdges {
+			if t.isBoundary(h) {
+				s.pinned[he.Origin] = true
+				s.pinned[t.Target(h)] = true
+			}
+		}
+	}
+	return s
+}
+
+// 余接の重みは頂点が動くたびに変わるので、毎回計算し直す
+func (s *laplacianSmoother) cotangentWeights() map[[2]int]float64 {
+	weights := make(map[[2]int]float64)
+	for _, f := range s.faces {
+		for k := 0; k < 3; k++ {
+			a, b, c := f[k], f[(k+1)%3], f[(k+2)%3]
+			u := s.positions[a].Sub(s.positions[c])
+			v := s.positions[b].Sub(s.positions[c])
+			cross := u.Cross(v).Length()
+			if cross == 0 {
+				continue
+			}
+			weights[indexEdgeKey(a, b)] += u.Dot(v) / cross / 2
+		}
+	}
+	return weights
+}
+
+func (s *laplacianSmoother) step(factor float64) {
+	var weights map[[2]int]float64
+	if s.weighting == LaplacianCotangent {
+		weights = s.cotangentWeights()
+	}
+
+	next := make([]Vector3, len(s.positions))
+	for i, p := range s.positions {
+		next[i] = p
+		if s.pinned[i] || len(s.neighbors[i]) == 0 {
+			continue
+		}
+
+		sum, total := Zero(), 0.0
+		for _, j := range s.neighbors[i] {
+			w := 1.0
+			if weights != nil {
+				// 鈍角の三角形では負になるので切り捨てる
+				w = math.Max(weights[indexEdgeKey(i, j)], 0)
+			}
+			sum = sum.Add(s.positions[j].Sub(p).MulScalar(w))
+			total += w
+		}
+		if total == 0 {
+			continue
+		}
+		next[i] = p.Add(sum.MulScalar(factor / total))
+	}
+	s.positions = next
+}
+
+// 面の並びやUV、スムージンググループはそのままに座標だけを書き戻す
+func (s *laplacianSmoother) apply(m *Mesh) {
+	for i, f := range m.Faces {
+		idx := s.faces[i]
+		f.V1.Coordinates = s.positions[idx[0]]
+		f.V2.Coordinates = s.positions[idx[1]]
+		f.V3.Coordinates = s.positions[idx[2]]
+	}
+
+	m.recomputeNormals()
+	if m.bvh != nil {
+		m.BuildBVH()
+	}
+}
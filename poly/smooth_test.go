@@ -0,0 +1,103 @@
+package poly
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	. "github.com/arata-nvm/poly/vecmath"
+)
+
+// 半径1の球の頂点を半径方向にずらしたメッシュ
+// 同じ座標の頂点は同じだけずらし、面のつながりを保つ
+func noisySphere(r *rand.Rand, noise float64) *Mesh {
+	m := MarchingCubes(SphereSDF(Zero(), 1), AABB{Min: NewVector3(-1.5, -1.5, -1.5), Max: NewVector3(1.5, 1.5, 1.5)}, 16, 0)
+	moved := make(map[Vector3]Vector3)
+	for _, f := range m.Faces {
+		for _, v := range []*Vertex{&f.V1, &f.V2, &f.V3} {
+			p, ok := moved[v.Coordinates]
+			if !ok {
+				p = v.Coordinates.MulScalar(1 + (r.Float64()*2-1)*noise)
+				moved[v.Coordinates] = p
+			}
+			v.Coordinates = p
+		}
+	}
+	return m
+}
+
+// 頂点の原点からの距離の1とのずれの平均
+func sphereRoughness(m *Mesh) float64 {
+	sum, n := 0.0, 0
+	for _, f := range m.Faces {
+		for _, v := range []Vertex{f.V1, f.V2, f.V3} {
+			sum += math.Abs(v.Coordinates.Length() - 1)
+			n++
+		}
+	}
+	return sum / float64(n)
+}
+
+func TestSmoothVolume(t *testing.T) {
+	cases := []struct {
+		name   string
+		smooth func(m *Mesh)
+		// 元の体積に対する比の範囲
+		minRatio, maxRatio float64
+		// 球面からのずれが減るか。縮む場合は確かめない
+		denoise bool
+	}{
+		// ラプラシアン平滑化は繰り返すと縮む
+		{"laplacian uniform", func(m *Mesh) { m.SmoothLaplacian(LaplacianUniform, 0.5, 20, false) }, 0, 0.9, false},
+		{"laplacian cotangent", func(m *Mesh) { m.SmoothLaplacian(LaplacianCotangent, 0.5, 20, false) }, 0, 0.9, false},
+		{"taubin uniform", func(m *Mesh) { m.SmoothTaubin(LaplacianUniform, 0.5, -0.53, 20, false) }, 0.97, 1.03, true},
+		{"taubin cotangent", func(m *Mesh) { m.SmoothTaubin(LaplacianCotangent, 0.5, -0.53, 20, false) }, 0.97, 1.03, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := noisySphere(rand.New(rand.NewSource(1)), 0.05)
+			volume := m.Volume()
+			roughness := sphereRoughness(m)
+
+			c.smooth(m)
+			ratio := m.Volume() / volume
+			if ratio < c.minRatio || ratio > c.maxRatio {
+				t.Fatalf("volume changed by a factor of %v, want %v to %v", ratio, c.minRatio, c.maxRatio)
+			}
+			if c.denoise && sphereRoughness(m) >= roughness/2 {
+				t.Fatalf("roughness went from %v to %v", roughness, sphereRoughness(m))
+			}
+			if r := m.Validate(); !r.OK() {
+				t.Fatalf("smoothed mesh is broken: %+v", r)
+			}
+		})
+	}
+}
+
+func TestSmoothPinBoundary(t *testing.T) {
+	m := wavyTerrainMesh(9)
+	before := m.Topology()
+	pinned := make(map[Vector3]bool)
+	for _, loop := range before.BoundaryLoops() {
+		for _, v := range loop {
+			pinned[before.Positions[v]] = true
+		}
+	}
+
+	m.SmoothTaubin(LaplacianCotangent, 0.5, -0.53, 10, true)
+
+	after := m.Topology()
+	kept := 0
+	for _, loop := range after.BoundaryLoops() {
+		for _, v := range loop {
+			if !pinned[after.Positions[v]] {
+				t.Fatalf("boundary vertex moved to %v", after.Positions[v])
+			}
+			kept++
+		}
+	}
+	if kept != len(pinned) {
+		t.Fatalf("got %d boundary vertices, want %d", kept, len(pinned))
+	}
+}
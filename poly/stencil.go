@@ -0,0 +1,93 @@
+package poly
+
+type StencilOp int
+
+const (
+	StencilKeep StencilOp = iota
+	StencilZero
+	StencilReplace
+	StencilIncr
+	StencilIncrWrap
+	StencilDecr
+	StencilDecrWrap
+	StencilInvert
+)
+
+func (d *Device) SetStencilTest(enabled bool) {
+	d.stencilTest = enabled
+}
+
+func (d *Device) SetStencilFunc(f CompareFunc, ref, mask uint8) {
+	d.stencilFunc = f
+	d.stencilRef = ref
+	d.stencilMask = mask
+}
+
+func (d *Device) SetStencilOp(fail, depthFail, pass StencilOp) {
+	d.stencilFail = fail
+	d.stencilDepthFail = depthFail
+	d.stencilPass = pass
+}
+
+func (d *Device) SetStencilWriteMask(mask uint8) {
+	d.stencilWriteMask = mask
+}
+
+func (d *Device) ClearStencilBuffer(v uint8) {
+	if !d.scissorTest {
+		for i := range d.framebuffer.Stencil {
+			d.framebuffer.Stencil[i] = v
+		}
+		return
+	}
+	d.forEachScissored(func(i int) {
+		d.framebuffer.Stencil[i] = v
+	})
+}
+
+func (d *Device) StencilBuffer() []uint8 {
+	return d.framebuffer.Stencil
+}
+
+func (d *Device) stencilPassed(index int) bool {
+	if !d.stencilTest {
+		return true
+	}
+
+	ref := float64(d.stencilRef & d.stencilMask)
+	stored := float64(d.framebuffer.Stencil[index] & d.stencilMask)
+	return d.stencilFunc.Test(ref, stored)
+}
+
+func (d *Device) updateStencil(index int, op StencilOp) {
+	if !d.stencilTest || op == StencilKeep {
+		return
+	}
+
+	s := d.framebuffer.Stencil[index]
+	var v uint8
+	switch op {
+	case StencilZero:
+		v = 0
+	case StencilReplace:
+		v = d.stencilRef
+	case StencilIncr:
+		v = s
+		if s < 0xff {
+			v++
+		}
+	case StencilIncrWrap:
+		v = s + 1
+	case StencilDecr:
+		v = s
+		if s > 0 {
+			v--
+		}
+	case StencilDecrWrap:
+		v = s - 1
+	case StencilInvert:
+		v = ^s
+	}
+
+	d.framebuffer.Stencil[index] = s&^d.stencilWriteMask | v&d.stencilWriteMask
+}
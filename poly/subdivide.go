@@ -0,0 +1,455 @@
+package poly
+
+import (
+	"math"
+
+	. "github.com/arata-nvm/poly/vecmath"
+)
+
+// 折り目の辺は、境界の辺と同じく細分割しても鋭いまま残る
+func (m *Mesh) AddCrease(a, b Vector3) {
+	if m.creases == nil {
+		m.creases = make(map[[2]Vector3]bool)
+	}
+	m.creases[edgeKey(a, b)] = true
+}
+
+func (m *Mesh) IsCrease(a, b Vector3) bool {
+	return m.creases[edgeKey(a, b)]
+}
+
+func (m *Mesh) ClearCreases() {
+	m.creases = nil
+}
+
+// 隣り合う面のなす角がangle(度数法)を超える辺をすべて折り目にする
+func (m *Mesh) MarkCreases(angle float64) {
+	pm := newPolygonMesh(m, true)
+	edges, _, _ := pm.buildEdges()
+	threshold := math.Cos(angle * math.Pi / 180)
+	for _, e := range edges {
+		if len(e.faces) != 2 {
+			continue
+		}
+		n1, n2 := pm.polygonNormal(e.faces[0]), pm.polygonNormal(e.faces[1])
+		if n1.Dot(n2) < threshold {
+			m.AddCrease(pm.positions[e.a], pm.positions[e.b])
+		}
+	}
+}
+
+// Loop法による細分割。多角形は三角形に分割されたものとして扱う
+// UVは各面の中で線形に補間し、法線は計算し直す
+func (m *Mesh) SubdivideLoop(levels int) {
+	pm := newPolygonMesh(m, false)
+	for i := 0; i < levels; i++ {
+		pm = pm.subdivideTriangles(true)
+	}
+	pm.apply(m, true)
+}
+
+// Catmull–Clark法による細分割。Polygonsがあれば元の多角形を使う
+// 結果は四角形になり、Polygonsに記録される
+func (m *Mesh) SubdivideCatmullClark(levels int) {
+	pm := newPolygonMesh(m, true)
+	for i := 0; i < levels; i++ {
+		pm = pm.subdivideCatmullClark()
+	}
+	pm.apply(m, true)
+}
+
+// 各三角形を辺の中点で4つに分ける。形も法線も変わらない
+func (m *Mesh) SubdivideMidpoint(levels int) {
+	pm := newPolygonMesh(m, false)
+	for i := 0; i < levels; i++ {
+		pm = pm.subdivideTriangles(false)
+	}
+	pm.apply(m, false)
+}
+
+func edgeKey(a, b Vector3) [2]Vector3 {
+	if b.X < a.X || (b.X == a.X && (b.Y < a.Y || (b.Y == a.Y && b.Z < a.Z))) {
+		a, b = b, a
+	}
+	return [2]Vector3{a, b}
+}
+
+func indexEdgeKey(a, b int) [2]int {
+	if b < a {
+		a, b = b, a
+	}
+	return [2]int{a, b}
+}
+
+// 同じ座標の頂点をまとめた多角形メッシュ
+// UVや法線は多角形の角ごとに、スムージンググループは多角形ごとに持つ
+type polygonMesh struct {
+	positions []Vector3
+	polygons  [][]int
+	corners   [][]Vertex
+	groups    []int
+	creases   map[[2]int]bool
+}
+
+type polygonEdge struct {
+	a, b   int
+	faces  []int
+	crease bool
+}
+
+// 境界の辺、折り目、3枚以上の面が接する辺
+func (e *polygonEdge) sharp() bool {
+	return e.crease || len(e.faces) != 2
+}
+
+func (e *polygonEdge) other(v int) int {
+	if e.a == v {
+		return e.b
+	}
+	return e.a
+}
+
+func validPolygons(m *Mesh) bool {
+	if m.Polygons == nil {
+		return false
+	}
+	n := 0
+	for _, p := range m.Polygons {
+		if p < 3 {
+			return false
+		}
+		n += p - 2
+	}
+	return n == len(m.Faces)
+}
+
+func newPolygonMesh(m *Mesh, keepPolygons bool) *polygonMesh {
+	pm := &polygonMesh{creases: make(map[[2]int]bool)}
+
+	index := make(map[Vector3]int)
+	lookup := func(p Vector3) int {
+		if i, ok := index[p]; ok {
+			return i
+		}
+		index[p] = len(pm.positions)
+		pm.positions = append(pm.positions, p)
+		return index[p]
+	}
+
+	sizes := m.Polygons
+	if !keepPolygons || !validPolygons(m) {
+		sizes = make([]int, len(m.Faces))
+		for i := range sizes {
+			sizes[i] = 3
+		}
+	}
+
+	next := 0
+	for _, n := range sizes {
+		first := m.Faces[next]
+		vs := []Vertex{first.V1, first.V2, first.V3}
+		for _, f := range m.Faces[next+1 : next+n-2] {
+			vs = append(vs, f.V3)
+		}
+		next += n - 2
+
+		poly := make([]int, len(vs))
+		for i, v := range vs {
+			poly[i] = lookup(v.Coordinates)
+		}
+		pm.add(poly, vs, first.SmoothingGroup)
+	}
+
+	for e := range m.creases {
+		a, okA := index[e[0]]
+		b, okB := index[e[1]]
+		if okA && okB {
+			pm.creases[indexEdgeKey(a, b)] = true
+		}
+	}
+	return pm
+}
+
+// 辺の一覧と、各頂点に接する辺・面の番号を返す
+func (pm *polygonMesh) buildEdges() ([]*polygonEdge, [][]int, [][]int) {
+	var edges []*polygonEdge
+	index := make(map[[2]int]int)
+	vertEdges := make([][]int, len(pm.positions))
+	vertFaces := make([][]int, len(pm.positions))
+
+	for fi, poly := range pm.polygons {
+		for k, a := range poly {
+			vertFaces[a] = append(vertFaces[a], fi)
+
+			b := poly[(k+1)%len(poly)]
+			key := indexEdgeKey(a, b)
+			i, ok := index[key]
+			if !ok {
+				i = len(edges)
+				index[key] = i
+				edges = append(edges, &polygonEdge{a: key[0], b: key[1], crease: pm.creases[key]})
+				vertEdges[key[0]] = append(vertEdges[key[0]], i)
+				vertEdges[key[1]] = append(vertEdges[key[1]], i)
+			}
+			edges[i].faces = append(edges[i].faces, fi)
+		}
+	}
+	return edges, vertEdges, vertFaces
+}
+
+func (pm *polygonMesh) edgeIndex(edges []*polygonEdge, vertEdges [][]int, a, b int) int {
+	for _, i := range vertEdges[a] {
+		if edges[i].other(a) == b {
+			return i
+		}
+	}
+	return -1
+}
+
+// Newellの方法による多角形の法線
+func (pm *polygonMesh) polygonNormal(face int) Vector3 {
+	n := Zero()
+	poly := pm.polygons[face]
+	for k, a := range poly {
+		p, q := pm.positions[a], pm.positions[poly[(k+1)%len(poly)]]
+		n = n.Add(NewVector3(
+			(p.Y-q.Y)*(p.Z+q.Z),
+			(p.Z-q.Z)*(p.X+q.X),
+			(p.X-q.X)*(p.Y+q.Y),
+		))
+	}
+	if n.LengthSq() == 0 {
+		return n
+	}
+	return n.Normalize()
+}
+
+// 頂点に接する鋭い辺の反対側の頂点
+func (pm *polygonMesh) sharpNeighbors(v int, edges []*polygonEdge, vertEdges [][]int) []int {
+	var ns []int
+	for _, i := range vertEdges[v] {
+		if edges[i].sharp() {
+			ns = append(ns, edges[i].other(v))
+		}
+	}
+	return ns
+}
+
+// 3本以上の鋭い辺が集まる頂点と、2本の辺がどちらも鋭い頂点(平面の角など)は動かさない
+func (pm *polygonMesh) isCorner(v int, sharp []int, vertEdges [][]int) bool {
+	n := len(vertEdges[v])
+	return n == 0 || len(sharp) > 2 || (len(sharp) == 2 && n == 2)
+}
+
+// smoothがfalseなら中点で分割するだけで、頂点は動かさない
+func (pm *polygonMesh) subdivideTriangles(smooth bool) *polygonMesh {
+	edges, vertEdges, _ := pm.buildEdges()
+	n := len(pm.positions)
+	out := &polygonMesh{
+		positions: make([]Vector3, n+len(edges)),
+		creases:   make(map[[2]int]bool),
+	}
+
+	for v, p := range pm.positions {
+		if !smooth {
+			out.positions[v] = p
+			continue
+		}
+
+		sharp := pm.sharpNeighbors(v, edges, vertEdges)
+		switch {
+		case pm.isCorner(v, sharp, vertEdges):
+			out.positions[v] = p
+		case len(sharp) == 2:
+			a, b := pm.positions[sharp[0]], pm.positions[sharp[1]]
+			out.positions[v] = p.MulScalar(0.75).Add(a.Add(b).MulScalar(0.125))
+		default:
+			k := float64(len(vertEdges[v]))
+			c := 3.0/8 + math.Cos(2*math.Pi/k)/4
+			beta := (5.0/8 - c*c) / k
+			sum := Zero()
+			for _, i := range vertEdges[v] {
+				sum = sum.Add(pm.positions[edges[i].other(v)])
+			}
+			out.positions[v] = p.MulScalar(1 - k*beta).Add(sum.MulScalar(beta))
+		}
+	}
+
+	for i, e := range edges {
+		a, b := pm.positions[e.a], pm.positions[e.b]
+		mid := a.Add(b).MulScalar(0.5)
+		if !smooth || e.sharp() {
+			out.positions[n+i] = mid
+			continue
+		}
+
+		opposite := Zero()
+		for _, f := range e.faces {
+			for _, v := range pm.polygons[f] {
+				if v != e.a && v != e.b {
+					opposite = opposite.Add(pm.positions[v])
+				}
+			}
+		}
+		out.positions[n+i] = a.Add(b).MulScalar(3.0 / 8).Add(opposite.MulScalar(1.0 / 8))
+	}
+
+	for fi, poly := range pm.polygons {
+		c, g := pm.corners[fi], pm.groups[fi]
+		v := [3]int{poly[0], poly[1], poly[2]}
+		var e [3]int
+		var mid [3]Vertex
+		for k := 0; k < 3; k++ {
+			e[k] = n + pm.edgeIndex(edges, vertEdges, v[k], v[(k+1)%3])
+			mid[k] = averageVertex(c[k], c[(k+1)%3])
+		}
+
+		out.add([]int{v[0], e[0], e[2]}, []Vertex{c[0], mid[0], mid[2]}, g)
+		out.add([]int{v[1], e[1], e[0]}, []Vertex{c[1], mid[1], mid[0]}, g)
+		out.add([]int{v[2], e[2], e[1]}, []Vertex{c[2], mid[2], mid[1]}, g)
+		out.add([]int{e[0], e[1], e[2]}, []Vertex{mid[0], mid[1], mid[2]}, g)
+	}
+
+	out.splitCreases(edges, n)
+	return out
+}
+
+func (pm *polygonMesh) subdivideCatmullClark() *polygonMesh {
+	edges, vertEdges, vertFaces := pm.buildEdges()
+	n := len(pm.positions)
+	facePoint := n + len(edges)
+	out := &polygonMesh{
+		positions: make([]Vector3, facePoint+len(pm.polygons)),
+		creases:   make(map[[2]int]bool),
+	}
+
+	for fi, poly := range pm.polygons {
+		sum := Zero()
+		for _, v := range poly {
+			sum = sum.Add(pm.positions[v])
+		}
+		out.positions[facePoint+fi] = sum.DivScalar(float64(len(poly)))
+	}
+
+	for i, e := range edges {
+		a, b := pm.positions[e.a], pm.positions[e.b]
+		if e.sharp() {
+			out.positions[n+i] = a.Add(b).MulScalar(0.5)
+			continue
+		}
+		f1, f2 := out.positions[facePoint+e.faces[0]], out.positions[facePoint+e.faces[1]]
+		out.positions[n+i] = a.Add(b).Add(f1).Add(f2).MulScalar(0.25)
+	}
+
+	for v, p := range pm.positions {
+		sharp := pm.sharpNeighbors(v, edges, vertEdges)
+		switch {
+		case pm.isCorner(v, sharp, vertEdges):
+			out.positions[v] = p
+		case len(sharp) == 2:
+			a, b := pm.positions[sharp[0]], pm.positions[sharp[1]]
+			out.positions[v] = a.Add(b).Add(p.MulScalar(6)).MulScalar(1.0 / 8)
+		default:
+			f := Zero()
+			for _, fi := range vertFaces[v] {
+				f = f.Add(out.positions[facePoint+fi])
+			}
+			f = f.DivScalar(float64(len(vertFaces[v])))
+
+			r := Zero()
+			for _, i := range vertEdges[v] {
+				r = r.Add(p.Add(pm.positions[edges[i].other(v)]).MulScalar(0.5))
+			}
+			k := float64(len(vertEdges[v]))
+			r = r.DivScalar(k)
+
+			out.positions[v] = f.Add(r.MulScalar(2)).Add(p.MulScalar(k - 3)).DivScalar(k)
+		}
+	}
+
+	for fi, poly := range pm.polygons {
+		c := pm.corners[fi]
+		center := averageVertex(c...)
+		k := len(poly)
+		for j, v := range poly {
+			next, prev := (j+1)%k, (j+k-1)%k
+			eNext := n + pm.edgeIndex(edges, vertEdges, v, poly[next])
+			ePrev := n + pm.edgeIndex(edges, vertEdges, poly[prev], v)
+			out.add(
+				[]int{v, eNext, facePoint + fi, ePrev},
+				[]Vertex{c[j], averageVertex(c[j], c[next]), center, averageVertex(c[prev], c[j])},
+				pm.groups[fi],
+			)
+		}
+	}
+
+	out.splitCreases(edges, n)
+	return out
+}
+
+func (pm *polygonMesh) add(poly []int, corners []Vertex, group int) {
+	pm.polygons = append(pm.polygons, poly)
+	pm.corners = append(pm.corners, corners)
+	pm.groups = append(pm.groups, group)
+}
+
+// 折り目の辺は、中点で分かれた2本の辺に引き継ぐ
+func (pm *polygonMesh) splitCreases(edges []*polygonEdge, edgePoint int) {
+	for i, e := range edges {
+		if e.crease {
+			pm.creases[indexEdgeKey(e.a, edgePoint+i)] = true
+			pm.creases[indexEdgeKey(edgePoint+i, e.b)] = true
+		}
+	}
+}
+
+// 多角形は扇状に三角形に分割してメッシュに書き戻す
+func (pm *polygonMesh) apply(m *Mesh, recalcNormals bool) {
+	m.Faces = make([]*Face, 0, len(pm.polygons))
+	m.Polygons = nil
+	hasPolygons := false
+	for fi, poly := range pm.polygons {
+		c := pm.corners[fi]
+		for k, v := range poly {
+			c[k].Coordinates = pm.positions[v]
+			if !recalcNormals && c[k].Normal.LengthSq() > 0 {
+				c[k].Normal = c[k].Normal.Normalize()
+			}
+		}
+		for k := 2; k < len(poly); k++ {
+			m.Faces = append(m.Faces, &Face{V1: c[0], V2: c[k-1], V3: c[k], SmoothingGroup: pm.groups[fi]})
+		}
+		m.Polygons = append(m.Polygons, len(poly))
+		if len(poly) > 3 {
+			hasPolygons = true
+		}
+	}
+	if !hasPolygons {
+		m.Polygons = nil
+	}
+
+	m.creases = nil
+	for e := range pm.creases {
+		m.AddCrease(pm.positions[e[0]], pm.positions[e[1]])
+	}
+
+	if recalcNormals {
+		m.recomputeNormals()
+	}
+	if m.bvh != nil {
+		m.BuildBVH()
+	}
+}
+
+// UVと法線を平均する(座標は後で決める)
+func averageVertex(vs ...Vertex) Vertex {
+	var v Vertex
+	for _, w := range vs {
+		v.Uv = v.Uv.Add(w.Uv)
+		v.Normal = v.Normal.Add(w.Normal)
+	}
+	f := 1 / float64(len(vs))
+	v.Uv = v.Uv.MulScalar(f)
+	v.Normal = v.Normal.MulScalar(f)
+	return v
+}
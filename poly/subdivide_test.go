@@ -0,0 +1,90 @@
+package poly
+
+import (
+	"testing"
+
+	. "github.com/arata-nvm/poly/vecmath"
+)
+
+// 外向きの四角形6枚からなる一辺2の立方体。各四角形は扇状に分割してPolygonsに記録する
+func quadCube() *Mesh {
+	corner := func(i int) Vector3 {
+		return NewVector3(float64(i&1)*2-1, float64(i>>1&1)*2-1, float64(i>>2&1)*2-1)
+	}
+	quads := [][4]int{
+		{0, 2, 3, 1}, {4, 5, 7, 6},
+		{0, 1, 5, 4}, {2, 6, 7, 3},
+		{0, 4, 6, 2}, {1, 3, 7, 5},
+	}
+
+	m := NewMesh()
+	for _, q := range quads {
+		for k := 2; k < 4; k++ {
+			m.Faces = append(m.Faces, &Face{
+				V1: Vertex{Coordinates: corner(q[0])},
+				V2: Vertex{Coordinates: corner(q[k-1])},
+				V3: Vertex{Coordinates: corner(q[k])},
+			})
+		}
+		m.Polygons = append(m.Polygons, 4)
+	}
+	return m
+}
+
+func tetrahedron() *Mesh {
+	return ConvexHull([]Vector3{Zero(), UnitX(), UnitY(), UnitZ()})
+}
+
+// 多角形を1つの面として数えた頂点、辺、面の数
+func polygonCounts(m *Mesh) (int, int, int) {
+	pm := newPolygonMesh(m, true)
+	edges, _, _ := pm.buildEdges()
+	return len(pm.positions), len(edges), len(pm.polygons)
+}
+
+func TestSubdivideCounts(t *testing.T) {
+	cases := []struct {
+		name      string
+		mesh      func() *Mesh
+		subdivide func(m *Mesh)
+		// 1段階分割した後の頂点、辺、面の数
+		v, e, f int
+		// 結果の多角形の頂点数
+		sides int
+	}{
+		// 三角形は4つに分かれ、頂点は辺の数だけ増える
+		{"loop tetrahedron", tetrahedron, func(m *Mesh) { m.SubdivideLoop(1) }, 10, 24, 16, 3},
+		{"loop cube", quadCube, func(m *Mesh) { m.SubdivideLoop(1) }, 26, 72, 48, 3},
+		{"midpoint tetrahedron", tetrahedron, func(m *Mesh) { m.SubdivideMidpoint(1) }, 10, 24, 16, 3},
+		// n角形はn個の四角形に分かれ、頂点は辺と面の数だけ増える
+		{"catmull-clark cube", quadCube, func(m *Mesh) { m.SubdivideCatmullClark(1) }, 26, 48, 24, 4},
+		{"catmull-clark tetrahedron", tetrahedron, func(m *Mesh) { m.SubdivideCatmullClark(1) }, 14, 24, 12, 4},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := c.mesh()
+			c.subdivide(m)
+
+			v, e, f := polygonCounts(m)
+			if v != c.v || e != c.e || f != c.f {
+				t.Fatalf("got V=%d E=%d F=%d, want V=%d E=%d F=%d", v, e, f, c.v, c.e, c.f)
+			}
+			if c.sides > 3 {
+				if len(m.Polygons) != f {
+					t.Fatalf("got %d polygons, want %d", len(m.Polygons), f)
+				}
+				for _, n := range m.Polygons {
+					if n != c.sides {
+						t.Fatalf("got a polygon with %d sides, want %d", n, c.sides)
+					}
+				}
+			} else if len(m.Faces) != f {
+				t.Fatalf("got %d triangles, want %d", len(m.Faces), f)
+			}
+			if r := m.Validate(); !r.OK() {
+				t.Fatalf("subdivided mesh is not closed and consistently wound: %+v", r)
+			}
+		})
+	}
+}
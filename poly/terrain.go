@@ -0,0 +1,251 @@
+package poly
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	. "github.com/arata-nvm/poly/vecmath"
+)
+
+// 行ごとに並べた高さの格子。0行目が奥(-Z)、各行の0列目が左(-X)になる
+type Heightmap struct {
+	Width  int
+	Height int
+
+	Values []float64
+}
+
+// valuesがnilなら0で埋めた格子を作る
+// 大きさが正でない場合と、valuesの長さがwidth*heightでない場合はpanicする
+func NewHeightmap(width, height int, values []float64) *Heightmap {
+	if width <= 0 || height <= 0 {
+		panic(fmt.Sprintf("terrain: invalid heightmap size %dx%d", width, height))
+	}
+	if values == nil {
+		values = make([]float64, width*height)
+	}
+	if len(values) != width*height {
+		panic(fmt.Sprintf("terrain: heightmap of %dx%d needs %d values, got %d", width, height, width*height, len(values)))
+	}
+	return &Heightmap{
+		Width:  width,
+		Height: height,
+		Values: values,
+	}
+}
+
+// 画素の明るさを0から1の高さにする。16bitのグレースケール画像は精度を落とさずに読む
+// テクスチャから作る場合はTexture.Imageを渡す
+func HeightmapFromImage(img image.Image) *Heightmap {
+	rect := img.Bounds()
+	h := NewHeightmap(rect.Dx(), rect.Dy(), nil)
+	for y := 0; y < h.Height; y++ {
+		for x := 0; x < h.Width; x++ {
+			g := color.Gray16Model.Convert(img.At(rect.Min.X+x, rect.Min.Y+y)).(color.Gray16)
+			h.Set(x, y, float64(g.Y)/0xffff)
+		}
+	}
+	return h
+}
+
+// 範囲外は端の値になる
+func (h *Heightmap) Get(x, y int) float64 {
+	x = Max(0, Min(x, h.Width-1))
+	y = Max(0, Min(y, h.Height-1))
+	return h.Values[y*h.Width+x]
+}
+
+func (h *Heightmap) Set(x, y int, v float64) {
+	h.Values[y*h.Width+x] = v
+}
+
+// 格子上の連続した位置(x, y)での値を双線形補間で求める
+func (h *Heightmap) Sample(x, y float64) float64 {
+	x = Clamp(x, 0, float64(h.Width-1))
+	y = Clamp(y, 0, float64(h.Height-1))
+	i, j := int(x), int(y)
+	fx, fy := x-float64(i), y-float64(j)
+	top := Interpolate(h.Get(i, j), h.Get(i+1, j), fx)
+	bottom := Interpolate(h.Get(i, j+1), h.Get(i+1, j+1), fx)
+	return Interpolate(top, bottom, fy)
+}
+
+// 高さの格子から地形のメッシュを作る
+// NewPlaneと同じくXZ平面上で原点を中心に広がり、u = x/Size.X + 0.5, v = 0.5 - z/Size.Zとなる
+// そのため高さの元画像をそのままテクスチャとして貼ることができる
+type Terrain struct {
+	Heightmap *Heightmap
+	// X, Zは地形全体の大きさ、Yは高さの倍率
+	Size Vector3
+	// 0より大きければ外周にこの深さの垂直な面を付け、LODの異なるチャンクの間の隙間を隠す
+	SkirtDepth float64
+}
+
+// 格子は2x2以上、大きさのX, Zは正でなければならず、そうでなければpanicする
+func NewTerrain(h *Heightmap, size Vector3) *Terrain {
+	if h.Width < 2 || h.Height < 2 {
+		panic(fmt.Sprintf("terrain: heightmap must be at least 2x2, got %dx%d", h.Width, h.Height))
+	}
+	if !(size.X > 0 && size.Z > 0) {
+		panic(fmt.Sprintf("terrain: size must be positive in X and Z, got %v", size))
+	}
+	return &Terrain{
+		Heightmap: h,
+		Size:      size,
+	}
+}
+
+// 格子点(x, y)の位置
+func (t *Terrain) Point(x, y int) Vector3 {
+	return NewVector3(
+		t.Size.X*(float64(x)/float64(Max(t.Heightmap.Width-1, 1))-0.5),
+		t.Size.Y*t.Heightmap.Get(x, y),
+		t.Size.Z*(float64(y)/float64(Max(t.Heightmap.Height-1, 1))-0.5),
+	)
+}
+
+// 格子点(x, y)の法線。高さの中心差分から求めるので、チャンクやLODが違っても境目で一致する
+func (t *Terrain) Normal(x, y int) Vector3 {
+	h := t.Heightmap
+	x0, x1 := Max(x-1, 0), Min(x+1, h.Width-1)
+	y0, y1 := Max(y-1, 0), Min(y+1, h.Height-1)
+
+	n := NewVector3(0, 1, 0)
+	if x1 > x0 {
+		dx := t.Point(x1, y).Sub(t.Point(x0, y))
+		n.X = -dx.Y / dx.X
+	}
+	if y1 > y0 {
+		dz := t.Point(x, y1).Sub(t.Point(x, y0))
+		n.Z = -dz.Y / dz.Z
+	}
+	return n.Normalize()
+}
+
+// 地形の座標(x, z)での高さ。物を地面に置くときに使う
+func (t *Terrain) HeightAt(x, z float64) float64 {
+	h := t.Heightmap
+	gx := (x/t.Size.X + 0.5) * float64(h.Width-1)
+	gy := (z/t.Size.Z + 0.5) * float64(h.Height-1)
+	return t.Size.Y * h.Sample(gx, gy)
+}
+
+func (t *Terrain) vertex(x, y int) Vertex {
+	p := t.Point(x, y)
+	return Vertex{
+		Coordinates: p,
+		Normal:      t.Normal(x, y),
+		Uv:          NewVector3(p.X/t.Size.X+0.5, 0.5-p.Z/t.Size.Z, 0),
+	}
+}
+
+// 地形全体を1つのメッシュにする
+func (t *Terrain) Mesh() *Mesh {
+	return t.Region(0, 0, t.Heightmap.Width-1, t.Heightmap.Height-1, 1)
+}
+
+// 格子点(x0, y0)から(x1, y1)までの範囲を、step個おきの格子点でメッシュにする
+// 範囲の終わりの格子点は常に含める
+func (t *Terrain) Region(x0, y0, x1, y1, step int) *Mesh {
+	m := NewMesh()
+	xs, ys := terrainSamples(x0, x1, step), terrainSamples(y0, y1, step)
+	if len(xs) < 2 || len(ys) < 2 {
+		return m
+	}
+
+	// 上から見て反時計回りになるように並べる
+	for j := 0; j+1 < len(ys); j++ {
+		for i := 0; i+1 < len(xs); i++ {
+			a := t.vertex(xs[i], ys[j])
+			b := t.vertex(xs[i+1], ys[j])
+			c := t.vertex(xs[i], ys[j+1])
+			d := t.vertex(xs[i+1], ys[j+1])
+			m.Faces = append(m.Faces, &Face{V1: a, V2: c, V3: b}, &Face{V1: b, V2: c, V3: d})
+		}
+	}
+
+	if t.SkirtDepth > 0 {
+		last := func(s []int) int { return s[len(s)-1] }
+		t.addSkirt(m, xs, func(i int) (int, int) { return i, y0 }, NewVector3(0, 0, -1))
+		t.addSkirt(m, xs, func(i int) (int, int) { return i, last(ys) }, NewVector3(0, 0, 1))
+		t.addSkirt(m, ys, func(j int) (int, int) { return x0, j }, NewVector3(-1, 0, 0))
+		t.addSkirt(m, ys, func(j int) (int, int) { return last(xs), j }, NewVector3(1, 0, 0))
+	}
+	return m
+}
+
+// 辺に沿った格子点を真下にSkirtDepthだけ伸ばした面を、outwardの向きに向けて追加する
+// 法線とUVは元の格子点と同じにして、継ぎ目が目立たないようにする
+func (t *Terrain) addSkirt(m *Mesh, samples []int, point func(int) (int, int), outward Vector3) {
+	down := NewVector3(0, t.SkirtDepth, 0)
+	for k := 0; k+1 < len(samples); k++ {
+		a := t.vertex(point(samples[k]))
+		b := t.vertex(point(samples[k+1]))
+		a2, b2 := a, b
+		a2.Coordinates = a.Coordinates.Sub(down)
+		b2.Coordinates = b.Coordinates.Sub(down)
+
+		f1 := &Face{V1: a, V2: a2, V3: b}
+		f2 := &Face{V1: b, V2: a2, V3: b2}
+		n := a2.Coordinates.Sub(a.Coordinates).Cross(b.Coordinates.Sub(a.Coordinates))
+		if n.Dot(outward) < 0 {
+			f1.V2, f1.V3 = f1.V3, f1.V2
+			f2.V2, f2.V3 = f2.V3, f2.V2
+		}
+		m.Faces = append(m.Faces, f1, f2)
+	}
+}
+
+func terrainSamples(from, to, step int) []int {
+	if to < from {
+		return nil
+	}
+	step = Max(step, 1)
+	var s []int
+	for i := from; i < to; i += step {
+		s = append(s, i)
+	}
+	return append(s, to)
+}
+
+// 地形を分割した一部分。LODs[0]が最も細かい
+type TerrainChunk struct {
+	X, Y   int
+	Bounds AABB
+	LODs   []*Mesh
+}
+
+// カメラとの距離に応じたLODを選ぶ。距離がlodDistanceを超えるごとに1段階ずつ粗くする
+func (c *TerrainChunk) SelectLOD(eye Vector3, lodDistance float64) *Mesh {
+	center := c.Bounds.Min.Add(c.Bounds.Max).MulScalar(0.5)
+	level := 0
+	if lodDistance > 0 {
+		level = int(eye.Sub(center).Length() / lodDistance)
+	}
+	return c.LODs[Min(level, len(c.LODs)-1)]
+}
+
+// chunkSize個ずつの格子のチャンクに分け、それぞれに格子点の間隔を1, 2, 4, ...と倍にしたlevels段階のLODを作る
+// 間隔がchunkSizeを超える段階は作らない。LODを混ぜて描く場合はSkirtDepthを設定しておく
+func (t *Terrain) Chunks(chunkSize, levels int) []*TerrainChunk {
+	chunkSize = Max(chunkSize, 1)
+	h := t.Heightmap
+	var chunks []*TerrainChunk
+	for y0 := 0; y0 < h.Height-1; y0 += chunkSize {
+		for x0 := 0; x0 < h.Width-1; x0 += chunkSize {
+			x1, y1 := Min(x0+chunkSize, h.Width-1), Min(y0+chunkSize, h.Height-1)
+			c := &TerrainChunk{X: x0 / chunkSize, Y: y0 / chunkSize}
+			for level := 0; level < Max(levels, 1); level++ {
+				step := 1 << level
+				if step > chunkSize {
+					break
+				}
+				c.LODs = append(c.LODs, t.Region(x0, y0, x1, y1, step))
+			}
+			c.Bounds = c.LODs[0].BoundingBox()
+			chunks = append(chunks, c)
+		}
+	}
+	return chunks
+}
@@ -0,0 +1,97 @@
+package poly
+
+import (
+	"testing"
+
+	. "github.com/arata-nvm/poly/vecmath"
+)
+
+// 高さが格子の位置の一次式になる5x4の地形
+// 世界座標ではX = x-2, Z = 2y-3, Y = 0.2(X+2) - 0.05(Z+3)の平面になる
+func planeTerrain() *Terrain {
+	h := NewHeightmap(5, 4, nil)
+	for y := 0; y < h.Height; y++ {
+		for x := 0; x < h.Width; x++ {
+			h.Set(x, y, 0.1*float64(x)-0.05*float64(y))
+		}
+	}
+	return NewTerrain(h, NewVector3(4, 2, 6))
+}
+
+func TestHeightmapGet(t *testing.T) {
+	h := planeTerrain().Heightmap
+	cases := []struct {
+		x, y int
+		want float64
+	}{
+		{0, 0, 0},
+		{4, 0, 0.4},
+		{2, 3, 0.2 - 0.15},
+		// 範囲外は端の値
+		{-1, -1, 0},
+		{9, 1, 0.4 - 0.05},
+		{1, 9, 0.1 - 0.15},
+	}
+
+	for _, c := range cases {
+		if got := h.Get(c.x, c.y); !ApproxEqual(got, c.want, 1e-12) {
+			t.Errorf("Get(%d, %d) = %v, want %v", c.x, c.y, got, c.want)
+		}
+	}
+}
+
+func TestTerrainPlane(t *testing.T) {
+	tr := planeTerrain()
+	want := NewVector3(-0.2, 1, 0.05).Normalize()
+
+	// 端の格子点でも片側の差分で同じ法線になる
+	for y := 0; y < tr.Heightmap.Height; y++ {
+		for x := 0; x < tr.Heightmap.Width; x++ {
+			if n := tr.Normal(x, y); !n.ApproxEqual(want, 1e-12) {
+				t.Errorf("Normal(%d, %d) = %v, want %v", x, y, n, want)
+			}
+			p := tr.Point(x, y)
+			if h := tr.HeightAt(p.X, p.Z); !ApproxEqual(h, p.Y, 1e-12) {
+				t.Errorf("HeightAt(%v, %v) = %v, want %v", p.X, p.Z, h, p.Y)
+			}
+		}
+	}
+
+	for _, p := range []Vector3{NewVector3(0.3, 0, -1.7), NewVector3(-1.25, 0, 2.5)} {
+		want := 0.2*(p.X+2) - 0.05*(p.Z+3)
+		if h := tr.HeightAt(p.X, p.Z); !ApproxEqual(h, want, 1e-12) {
+			t.Errorf("HeightAt(%v, %v) = %v, want %v", p.X, p.Z, h, want)
+		}
+	}
+
+	for _, f := range tr.Mesh().Faces {
+		n := f.V2.Coordinates.Sub(f.V1.Coordinates).Cross(f.V3.Coordinates.Sub(f.V1.Coordinates)).Normalize()
+		if !n.ApproxEqual(want, 1e-9) {
+			t.Fatalf("face normal %v, want %v", n, want)
+		}
+	}
+}
+
+func TestTerrainInvalid(t *testing.T) {
+	cases := []struct {
+		name string
+		f    func()
+	}{
+		{"zero size heightmap", func() { NewHeightmap(0, 4, nil) }},
+		{"short values", func() { NewHeightmap(2, 2, []float64{1, 2, 3}) }},
+		{"one column", func() { NewTerrain(NewHeightmap(1, 4, nil), NewVector3(1, 1, 1)) }},
+		{"one row", func() { NewTerrain(NewHeightmap(4, 1, nil), NewVector3(1, 1, 1)) }},
+		{"zero width", func() { NewTerrain(NewHeightmap(2, 2, nil), NewVector3(0, 1, 1)) }},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("did not panic")
+				}
+			}()
+			c.f()
+		})
+	}
+}
@@ -2,7 +2,10 @@ package poly
 
 import (
 	"image"
+	"math"
 	"os"
+
+	. "github.com/arata-nvm/poly/vecmath"
 )
 
 type Texture struct {
@@ -10,6 +13,7 @@ type Texture struct {
 	Height int
 
 	Image image.Image
+	SRGB  bool
 }
 
 func NewTexture(filename string) (*Texture, error) {
@@ -28,14 +32,38 @@ func NewTexture(filename string) (*Texture, error) {
 		Width:  rect.Max.X,
 		Height: rect.Max.Y,
 		Image:  img,
+		SRGB:   true,
 	}, nil
 }
 
+// 0から1の範囲外のUVは繰り返す。0と1ちょうどは端のテクセルになる
 func (t *Texture) Map(u, v float64) Color {
-	v = 1 - v
-	x := int(u * float64(t.Width))
-	y := int(v * float64(t.Height))
+	u = wrapUV(u)
+	v = 1 - wrapUV(v)
+	x := Min(int(u*float64(t.Width)), t.Width-1)
+	y := Min(int(v*float64(t.Height)), t.Height-1)
+
+	switch img := t.Image.(type) {
+	case *ColorBuffer:
+		return img.Get(x, y)
+	case *DepthBuffer:
+		z := img.Get(x, y)
+		return NewColor(z, z, z, 1)
+	}
+
 	r, g, b, a := t.Image.At(x, y).RGBA()
 	f := float64(0xffff)
-	return NewColor(float64(r)/f, float64(g)/f, float64(b)/f, float64(a)/f)
+	c := NewColor(float64(r)/f, float64(g)/f, float64(b)/f, float64(a)/f)
+	if t.SRGB {
+		c = NewColor(SRGBToLinear(c.R), SRGBToLinear(c.G), SRGBToLinear(c.B), c.A)
+	}
+	return c
+}
+
+// [0, 1]の外の値だけを[0, 1)に折り返す
+func wrapUV(x float64) float64 {
+	if x < 0 || x > 1 {
+		return x - math.Floor(x)
+	}
+	return x
 }
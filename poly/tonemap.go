@@ -0,0 +1,197 @@
+package poly
+
+import (
+	"image"
+	"math"
+	"sync"
+
+	. "github.com/arata-nvm/poly/vecmath"
+)
+
+type ToneMapper interface {
+	ToneMap(Color) Color
+}
+
+type ReinhardToneMapper struct {
+	White float64
+}
+
+func NewReinhardToneMapper(white float64) *ReinhardToneMapper {
+	return &ReinhardToneMapper{White: white}
+}
+
+func (t *ReinhardToneMapper) ToneMap(c Color) Color {
+	f := func(x float64) float64 {
+		if t.White > 0 {
+			return x * (1 + x/(t.White*t.White)) / (1 + x)
+		}
+		return x / (1 + x)
+	}
+	return Color{f(c.R), f(c.G), f(c.B), c.A}
+}
+
+type ACESToneMapper struct{}
+
+func NewACESToneMapper() *ACESToneMapper {
+	return &ACESToneMapper{}
+}
+
+// Narkowiczによるフィルミックカーブの近似
+func (t *ACESToneMapper) ToneMap(c Color) Color {
+	f := func(x float64) float64 {
+		return Clamp((x*(2.51*x+0.03))/(x*(2.43*x+0.59)+0.14), 0, 1)
+	}
+	return Color{f(c.R), f(c.G), f(c.B), c.A}
+}
+
+// 1 - e^(-x)。露出はDevice.SetExposureで掛けてから渡される
+type ExposureToneMapper struct{}
+
+func NewExposureToneMapper() *ExposureToneMapper {
+	return &ExposureToneMapper{}
+}
+
+func (t *ExposureToneMapper) ToneMap(c Color) Color {
+	f := func(x float64) float64 {
+		return 1 - math.Exp(-x)
+	}
+	return Color{f(c.R), f(c.G), f(c.B), c.A}
+}
+
+type Dither int
+
+const (
+	DitherNone Dither = iota
+	DitherOrdered
+	DitherBlueNoise
+)
+
+func LinearToSRGB(x float64) float64 {
+	x = Clamp(x, 0, 1)
+	if x <= 0.0031308 {
+		return x * 12.92
+	}
+	return 1.055*math.Pow(x, 1/2.4) - 0.055
+}
+
+func SRGBToLinear(x float64) float64 {
+	x = Clamp(x, 0, 1)
+	if x <= 0.04045 {
+		return x / 12.92
+	}
+	return math.Pow((x+0.055)/1.055, 2.4)
+}
+
+func (d *Device) SetToneMapper(t ToneMapper) {
+	d.toneMapper = t
+}
+
+// トーンマッピングの前に色に掛ける値。既定は1
+func (d *Device) SetExposure(exposure float64) {
+	d.exposure = exposure
+}
+
+func (d *Device) SetSRGB(enabled bool) {
+	d.srgb = enabled
+}
+
+func (d *Device) SetDither(dither Dither) {
+	d.dither = dither
+}
+
+func (d *Device) Resolve(b *ColorBuffer) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, b.Width, b.Height))
+	for y := 0; y < b.Height; y++ {
+		for x := 0; x < b.Width; x++ {
+			c := b.Pix[x+y*b.Width].MulScalar(d.exposure)
+			if d.toneMapper != nil {
+				c = d.toneMapper.ToneMap(c)
+			}
+			if d.srgb {
+				c = Color{LinearToSRGB(c.R), LinearToSRGB(c.G), LinearToSRGB(c.B), c.A}
+			}
+
+			if n, ok := ditherNoise(d.dither, x, y); ok {
+				n = (n - 0.5) / 255
+				c = Color{c.R + n, c.G + n, c.B + n, c.A}
+			}
+
+			img.SetNRGBA(x, y, c.NRGBA())
+		}
+	}
+	return img
+}
+
+func ditherNoise(dither Dither, x, y int) (float64, bool) {
+	switch dither {
+	case DitherOrdered:
+		return (float64(bayer8[y%8][x%8]) + 0.5) / 64, true
+	case DitherBlueNoise:
+		blueNoiseOnce.Do(generateBlueNoise)
+		return blueNoise[(y%blueNoiseSize)*blueNoiseSize+x%blueNoiseSize], true
+	default:
+		return 0, false
+	}
+}
+
+var bayer8 = [8][8]int{
+	{0, 32, 8, 40, 2, 34, 10, 42},
+	{48, 16, 56, 24, 50, 18, 58, 26},
+	{12, 44, 4, 36, 14, 46, 6, 38},
+	{60, 28, 52, 20, 62, 30, 54, 22},
+	{3, 35, 11, 43, 1, 33, 9, 41},
+	{51, 19, 59, 27, 49, 17, 57, 25},
+	{15, 47, 7, 39, 13, 45, 5, 37},
+	{63, 31, 55, 23, 61, 29, 53, 21},
+}
+
+const blueNoiseSize = 64
+
+var (
+	blueNoise     []float64
+	blueNoiseOnce sync.Once
+)
+
+// void-and-clusterの簡易版
+// エネルギーが最小の空き画素を順に埋めていき、埋めた順番を閾値とする
+func generateBlueNoise() {
+	const (
+		n      = blueNoiseSize
+		radius = 6
+		sigma  = 1.5
+	)
+
+	kernel := make([]float64, 0, (2*radius+1)*(2*radius+1))
+	for dy := -radius; dy <= radius; dy++ {
+		for dx := -radius; dx <= radius; dx++ {
+			kernel = append(kernel, math.Exp(-float64(dx*dx+dy*dy)/(2*sigma*sigma)))
+		}
+	}
+
+	energy := make([]float64, n*n)
+	filled := make([]bool, n*n)
+	blueNoise = make([]float64, n*n)
+
+	for rank := 0; rank < n*n; rank++ {
+		best := -1
+		for i := range energy {
+			if !filled[i] && (best < 0 || energy[i] < energy[best]) {
+				best = i
+			}
+		}
+
+		filled[best] = true
+		blueNoise[best] = (float64(rank) + 0.5) / float64(n*n)
+
+		bx, by := best%n, best/n
+		k := 0
+		for dy := -radius; dy <= radius; dy++ {
+			for dx := -radius; dx <= radius; dx++ {
+				x := (bx + dx + n) % n
+				y := (by + dy + n) % n
+				energy[x+y*n] += kernel[k]
+				k++
+			}
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package poly
+
+import (
+	"math"
+
+	. "github.com/arata-nvm/poly/vecmath"
+)
+
+// 距離がepsilon以下の頂点を同じ座標にまとめ、座標を書き換えた頂点の数を返す
+// 座標が完全に一致する頂点を同一視する処理(SmoothNormalsなど)の前に使う
+func (m *Mesh) Weld(epsilon float64) int {
+	if epsilon <= 0 {
+		return 0
+	}
+
+	type cell [3]int64
+	cellOf := func(p Vector3) cell {
+		return cell{
+			int64(math.Floor(p.X / epsilon)),
+			int64(math.Floor(p.Y / epsilon)),
+			int64(math.Floor(p.Z / epsilon)),
+		}
+	}
+
+	// 代表点を空間ハッシュに登録し、近くに代表点があればそれに寄せる
+	grid := make(map[cell][]Vector3)
+	snap := func(p Vector3) Vector3 {
+		if math.IsNaN(p.X) || math.IsNaN(p.Y) || math.IsNaN(p.Z) {
+			return p
+		}
+
+		c := cellOf(p)
+		best, bestDist := p, math.Inf(1)
+		for dx := int64(-1); dx <= 1; dx++ {
+			for dy := int64(-1); dy <= 1; dy++ {
+				for dz := int64(-1); dz <= 1; dz++ {
+					for _, q := range grid[cell{c[0] + dx, c[1] + dy, c[2] + dz}] {
+						if d := q.Sub(p).Length(); d <= epsilon && d < bestDist {
+							best, bestDist = q, d
+						}
+					}
+				}
+			}
+		}
+		if math.IsInf(bestDist, 1) {
+			grid[c] = append(grid[c], p)
+		}
+		return best
+	}
+
+	moved := 0
+	for _, f := range m.Faces {
+		for _, v := range []*Vertex{&f.V1, &f.V2, &f.V3} {
+			p := snap(v.Coordinates)
+			if p != v.Coordinates {
+				v.Coordinates = p
+				moved++
+			}
+		}
+	}
+
+	if moved > 0 && m.bvh != nil {
+		m.BuildBVH()
+	}
+	return moved
+}
+
+type HalfEdge struct {
+	Origin int
+	Face   int
+	Next   int
+	// 同じ辺を共有するもう一方の半辺。境界の辺と3枚以上の面が接する辺では-1
+	Twin int
+}
+
+// 座標が一致する頂点をまとめた半辺構造
+// 面iの半辺は3i, 3i+1, 3i+2番目にある
+type Topology struct {
+	Positions []Vector3
+	Faces     [][3]int
+	HalfEdges []HalfEdge
+
+	edges    map[[2]int][]int
+	edgeKeys [][2]int
+	// 頂点から出る半辺
+	outgoing [][]int
+}
+
+func (m *Mesh) Topology() *Topology {
+	im := newIndexedMesh(m)
+	t := &Topology{
+		Positions: im.positions,
+		Faces:     im.faces,
+		HalfEdges: make([]HalfEdge, 3*len(im.faces)),
+		edges:     make(map[[2]int][]int),
+		outgoing:  make([][]int, len(im.positions)),
+	}
+
+	for i, f := range t.Faces {
+		for k := 0; k < 3; k++ {
+			h := 3*i + k
+			t.HalfEdges[h] = HalfEdge{
+				Origin: f[k],
+				Face:   i,
+				Next:   3*i + (k+1)%3,
+				Twin:   -1,
+			}
+			key := indexEdgeKey(f[k], f[(k+1)%3])
+			if _, ok := t.edges[key]; !ok {
+				t.edgeKeys = append(t.edgeKeys, key)
+			}
+			t.edges[key] = append(t.edges[key], h)
+			t.outgoing[f[k]] = append(t.outgoing[f[k]], h)
+		}
+	}
+
+	for _, hs := range t.edges {
+		if len(hs) == 2 {
+			t.HalfEdges[hs[0]].Twin = hs[1]
+			t.HalfEdges[hs[1]].Twin = hs[0]
+		}
+	}
+	return t
+}
+
+// 半辺の終点
+func (t *Topology) Target(h int) int {
+	return t.HalfEdges[t.HalfEdges[h].Next].Origin
+}
+
+// 辺を共有する面(3枚以上の面が接する辺も含む)
+func (t *Topology) FaceNeighbors(face int) []int {
+	var ns []int
+	for k := 0; k < 3; k++ {
+		h := 3*face + k
+		for _, g := range t.edges[indexEdgeKey(t.HalfEdges[h].Origin, t.Target(h))] {
+			if g != h {
+				ns = append(ns, t.HalfEdges[g].Face)
+			}
+		}
+	}
+	return ns
+}
+
+// 頂点の周りの面を半辺でたどって隣の頂点を集める
+// 周りの面が辺でつながっていない頂点(非多様体や巻き順の揃わない部分)では、頂点から出る半辺をすべて調べる
+func (t *Topology) VertexNeighbors(v int) []int {
+	if len(t.outgoing[v]) == 0 {
+		return nil
+	}
+	start := t.outgoing[v][0]
+
+	var ns []int
+	add := func(w int) {
+		for _, n := range ns {
+			if n == w {
+				return
+			}
+		}
+		ns = append(ns, w)
+	}
+
+	// 面の中で頂点に入ってくる半辺の双子を、次の出ていく半辺とする
+	walked := 0
+	closed := false
+	for h := start; walked < len(t.outgoing[v]); {
+		walked++
+		add(t.Target(h))
+		in := t.HalfEdges[t.HalfEdges[h].Next].Next
+		twin := t.HalfEdges[in].Twin
+		if twin < 0 || t.HalfEdges[twin].Origin != v {
+			add(t.HalfEdges[in].Origin)
+			break
+		}
+		if h = twin; h == start {
+			closed = true
+			break
+		}
+	}
+
+	// 境界に当たったら反対向きにもたどる
+	for h := start; !closed && walked < len(t.outgoing[v]); {
+		twin := t.HalfEdges[h].Twin
+		if twin < 0 || t.HalfEdges[twin].Origin == v {
+			break
+		}
+		h = t.HalfEdges[twin].Next
+		walked++
+		add(t.Target(h))
+	}
+
+	if walked < len(t.outgoing[v]) {
+		for _, h := range t.outgoing[v] {
+			add(t.Target(h))
+			add(t.HalfEdges[t.HalfEdges[t.HalfEdges[h].Next].Next].Origin)
+		}
+	}
+	return ns
+}
+
+// 3枚以上の面が接する辺
+func (t *Topology) NonManifoldEdges() [][2]int {
+	var es [][2]int
+	for _, key := range t.edgeKeys {
+		if len(t.edges[key]) > 2 {
+			es = append(es, key)
+		}
+	}
+	return es
+}
+
+func (t *Topology) isBoundary(h int) bool {
+	return len(t.edges[indexEdgeKey(t.HalfEdges[h].Origin, t.Target(h))]) == 1
+}
+
+// 境界の辺をたどってできる閉路(穴の縁)を頂点番号の列で返す
+func (t *Topology) BoundaryLoops() [][]int {
+	outgoing := make(map[int][]int)
+	for h := range t.HalfEdges {
+		if t.isBoundary(h) {
+			o := t.HalfEdges[h].Origin
+			outgoing[o] = append(outgoing[o], h)
+		}
+	}
+
+	visited := make(map[int]bool)
+	var loops [][]int
+	for h := range t.HalfEdges {
+		if !t.isBoundary(h) || visited[h] {
+			continue
+		}
+
+		var loop []int
+		for cur := h; cur >= 0 && !visited[cur]; {
+			visited[cur] = true
+			loop = append(loop, t.HalfEdges[cur].Origin)
+
+			next := -1
+			for _, g := range outgoing[t.Target(cur)] {
+				if !visited[g] {
+					next = g
+					break
+				}
+			}
+			cur = next
+		}
+		loops = append(loops, loop)
+	}
+	return loops
+}
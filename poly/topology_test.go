@@ -0,0 +1,115 @@
+package poly
+
+import (
+	"math/rand"
+	"testing"
+
+	. "github.com/arata-nvm/poly/vecmath"
+)
+
+// 面ごとに頂点を複製し、座標をjitterだけずらした立方体
+func jitteredCube(r *rand.Rand, jitter float64) *Mesh {
+	m := quadCube()
+	m.Polygons = nil
+	for i, f := range m.Faces {
+		g := *f
+		for _, v := range []*Vertex{&g.V1, &g.V2, &g.V3} {
+			v.Coordinates = v.Coordinates.Add(NewVector3(r.Float64()-0.5, r.Float64()-0.5, r.Float64()-0.5).MulScalar(jitter))
+		}
+		m.Faces[i] = &g
+	}
+	return m
+}
+
+func TestWeld(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	cases := []struct {
+		name    string
+		mesh    *Mesh
+		epsilon float64
+		// 溶接後に閉じたメッシュになるか
+		closed bool
+	}{
+		{"exact", quadCube(), 1e-6, true},
+		{"jittered", jitteredCube(r, 1e-7), 1e-6, true},
+		{"too far apart", jitteredCube(r, 1e-3), 1e-6, false},
+		{"zero epsilon", jitteredCube(r, 1e-7), 0, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := c.mesh
+			moved := m.Weld(c.epsilon)
+			if c.epsilon <= 0 && moved != 0 {
+				t.Fatalf("moved %d vertices with epsilon %v", moved, c.epsilon)
+			}
+
+			topo := m.Topology()
+			if c.closed && len(topo.Positions) != 8 {
+				t.Fatalf("got %d distinct positions, want 8", len(topo.Positions))
+			}
+			if got := m.Validate().OK(); got != c.closed {
+				t.Fatalf("got OK() = %v after welding, want %v", got, c.closed)
+			}
+			if n := m.Weld(c.epsilon); n != 0 {
+				t.Fatalf("second Weld moved %d vertices", n)
+			}
+		})
+	}
+}
+
+func bowtie() *Mesh {
+	v := func(x, y float64) Vertex { return Vertex{Coordinates: NewVector3(x, y, 0)} }
+	m := NewMesh()
+	m.Faces = []*Face{
+		{V1: v(0, 0), V2: v(1, 1), V3: v(-1, 1)},
+		{V1: v(0, 0), V2: v(-1, -1), V3: v(1, -1)},
+	}
+	return m
+}
+
+func TestVertexNeighbors(t *testing.T) {
+	cases := []struct {
+		name string
+		mesh *Mesh
+		// 頂点ごとの隣の頂点の数
+		neighbors int
+	}{
+		{"tetrahedron", tetrahedron(), 3},
+		// 三角形に分割した立方体の角は3個か6個の隣を持つので、数は確かめない
+		{"cube", quadCube(), -1},
+		// 1点だけを共有する2枚の三角形では、共有点の周りを半辺でたどれない
+		{"bowtie", bowtie(), -1},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			topo := c.mesh.Topology()
+
+			// 面の辺から求めた隣接関係と一致する
+			want := make([]map[int]bool, len(topo.Positions))
+			for i := range want {
+				want[i] = make(map[int]bool)
+			}
+			for _, f := range topo.Faces {
+				for k := 0; k < 3; k++ {
+					a, b := f[k], f[(k+1)%3]
+					want[a][b] = true
+					want[b][a] = true
+				}
+			}
+
+			for v := range topo.Positions {
+				got := topo.VertexNeighbors(v)
+				if len(got) != len(want[v]) || (c.neighbors >= 0 && len(got) != c.neighbors) {
+					t.Fatalf("vertex %d: got neighbors %v, want %v", v, got, want[v])
+				}
+				for _, w := range got {
+					if !want[v][w] {
+						t.Fatalf("vertex %d: %d is not a neighbor", v, w)
+					}
+				}
+			}
+		})
+	}
+}
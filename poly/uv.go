@@ -0,0 +1,358 @@
+package poly
+
+import (
+	"math"
+	"sort"
+
+	. "github.com/arata-nvm/poly/vecmath"
+)
+
+// 以下のUVはすべてモデル座標系で計算し、バウンディングボックスに合わせて0から1の範囲に収める
+
+// normalに垂直な平面に投影する。UnitY()ならNewPlaneと同じ向きになる
+func (m *Mesh) ProjectPlanarUV(normal Vector3) {
+	u, v := planarBasis(normal)
+	project := func(p Vector3) Vector3 {
+		return NewVector3(p.Dot(u), p.Dot(v), 0)
+	}
+
+	for _, f := range m.Faces {
+		for _, vt := range []*Vertex{&f.V1, &f.V2, &f.V3} {
+			vt.Uv = project(vt.Coordinates)
+		}
+	}
+	m.fitUV()
+}
+
+// 面ごとに法線に最も近い軸の平面へ投影する。3つの投影は同じ範囲に重ねて置く
+func (m *Mesh) ProjectBoxUV() {
+	b := m.BoundingBox()
+	size := b.Size()
+	scale := math.Max(size.X, math.Max(size.Y, size.Z))
+	if scale == 0 {
+		return
+	}
+
+	for _, f := range m.Faces {
+		n := f.V2.Coordinates.Sub(f.V1.Coordinates).Cross(f.V3.Coordinates.Sub(f.V1.Coordinates))
+		u, v := planarBasis(dominantAxis(n))
+		// 軸が負の向きなら-1から0になるので、0から1にずらす
+		offset := NewVector3(math.Max(-(u.X+u.Y+u.Z), 0), math.Max(-(v.X+v.Y+v.Z), 0), 0)
+		for _, vt := range []*Vertex{&f.V1, &f.V2, &f.V3} {
+			p := vt.Coordinates.Sub(b.Min).DivScalar(scale)
+			vt.Uv = NewVector3(p.Dot(u), p.Dot(v), 0).Add(offset)
+		}
+	}
+}
+
+// バウンディングボックスの中心を通るY軸の周りの角度をu、高さをvにする
+func (m *Mesh) ProjectCylindricalUV() {
+	b := m.BoundingBox()
+	center := b.Min.Add(b.Max).MulScalar(0.5)
+	height := b.Size().Y
+
+	for _, f := range m.Faces {
+		for _, vt := range []*Vertex{&f.V1, &f.V2, &f.V3} {
+			p := vt.Coordinates.Sub(center)
+			v := 0.5
+			if height > 0 {
+				v = p.Y/height + 0.5
+			}
+			vt.Uv = NewVector3(azimuth(p), v, 0)
+		}
+		fixSeam(f, center)
+	}
+}
+
+// バウンディングボックスの中心から見た経度をu、緯度をvにする
+func (m *Mesh) ProjectSphericalUV() {
+	b := m.BoundingBox()
+	center := b.Min.Add(b.Max).MulScalar(0.5)
+
+	for _, f := range m.Faces {
+		for _, vt := range []*Vertex{&f.V1, &f.V2, &f.V3} {
+			p := vt.Coordinates.Sub(center)
+			v := 0.5
+			if l := p.Length(); l > 0 {
+				v = 1 - math.Acos(Clamp(p.Y/l, -1, 1))/math.Pi
+			}
+			vt.Uv = NewVector3(azimuth(p), v, 0)
+		}
+		fixSeam(f, center)
+	}
+}
+
+// 外から見て右へ増える、0から1の角度。継ぎ目は-Z側にある
+func azimuth(p Vector3) float64 {
+	return math.Atan2(p.X, p.Z)/(2*math.Pi) + 0.5
+}
+
+// 継ぎ目をまたぐ面のuが逆向きに一周しないよう、小さい側に1を足す
+// Texture.Mapは範囲外のUVを繰り返すので、そのまま描ける
+// 軸上の頂点は角度が決まらないので、残りの頂点のuの平均にする
+func fixSeam(f *Face, center Vector3) {
+	vs := []*Vertex{&f.V1, &f.V2, &f.V3}
+	var onAxis, others []*Vertex
+	for _, v := range vs {
+		p := v.Coordinates.Sub(center)
+		if p.X == 0 && p.Z == 0 {
+			onAxis = append(onAxis, v)
+		} else {
+			others = append(others, v)
+		}
+	}
+
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range others {
+		lo, hi = math.Min(lo, v.Uv.X), math.Max(hi, v.Uv.X)
+	}
+	if hi-lo > 0.5 {
+		for _, v := range others {
+			if v.Uv.X < 0.5 {
+				v.Uv.X++
+			}
+		}
+	}
+
+	if len(others) == 0 {
+		return
+	}
+	mean := 0.0
+	for _, v := range others {
+		mean += v.Uv.X
+	}
+	for _, v := range onAxis {
+		v.Uv.X = mean / float64(len(others))
+	}
+}
+
+// normalに垂直な平面上の、右向きと上向きの軸
+func planarBasis(normal Vector3) (Vector3, Vector3) {
+	n := normal.Normalize()
+	up := UnitY()
+	if math.Abs(n.Y) > 0.999 {
+		up = NewVector3(0, 0, -math.Copysign(1, n.Y))
+	}
+	v := up.Sub(n.MulScalar(up.Dot(n))).Normalize()
+	return v.Cross(n), v
+}
+
+// 成分の絶対値が最も大きい軸を、符号を付けて返す
+func dominantAxis(n Vector3) Vector3 {
+	ax, ay, az := math.Abs(n.X), math.Abs(n.Y), math.Abs(n.Z)
+	switch {
+	case ax >= ay && ax >= az:
+		return NewVector3(math.Copysign(1, n.X), 0, 0)
+	case ay >= az:
+		return NewVector3(0, math.Copysign(1, n.Y), 0)
+	default:
+		return NewVector3(0, 0, math.Copysign(1, n.Z))
+	}
+}
+
+// 縦横比を保ったまま、UVを0から1の範囲に収める
+func (m *Mesh) fitUV() {
+	lo := NewVector3(math.Inf(1), math.Inf(1), 0)
+	hi := NewVector3(math.Inf(-1), math.Inf(-1), 0)
+	for _, f := range m.Faces {
+		for _, v := range []Vertex{f.V1, f.V2, f.V3} {
+			lo.X, lo.Y = math.Min(lo.X, v.Uv.X), math.Min(lo.Y, v.Uv.Y)
+			hi.X, hi.Y = math.Max(hi.X, v.Uv.X), math.Max(hi.Y, v.Uv.Y)
+		}
+	}
+	scale := math.Max(hi.X-lo.X, hi.Y-lo.Y)
+	if scale <= 0 || math.IsInf(scale, 0) {
+		return
+	}
+
+	for _, f := range m.Faces {
+		for _, v := range []*Vertex{&f.V1, &f.V2, &f.V3} {
+			v.Uv = v.Uv.Sub(lo).DivScalar(scale)
+			v.Uv.Z = 0
+		}
+	}
+}
+
+// 隣り合う面を法線の向きの差がmaxAngle(度数法)以内に収まる範囲でまとめてチャートに分け、
+// 各チャートを平面に投影して1枚のアトラスに詰め込む
+// paddingはチャート同士の間隔で、アトラスの大きさに対する割合で指定する。チャートの数を返す
+// maxAngleが90未満なら投影で面が裏返ることはない
+func (m *Mesh) UnwrapUV(maxAngle, padding float64) int {
+	im := newIndexedMesh(m)
+	threshold := math.Cos(maxAngle * math.Pi / 180)
+
+	normals := make([]Vector3, len(im.faces))
+	areas := make([]float64, len(im.faces))
+	for i, f := range im.faces {
+		a, b, c := im.positions[f[0]], im.positions[f[1]], im.positions[f[2]]
+		n := b.Sub(a).Cross(c.Sub(a))
+		areas[i] = n.Length() / 2
+		if areas[i] > 0 {
+			normals[i] = n.Normalize()
+		}
+	}
+
+	edgeFaces := make(map[[2]int][]int)
+	for i, f := range im.faces {
+		for k := 0; k < 3; k++ {
+			key := indexEdgeKey(f[k], f[(k+1)%3])
+			edgeFaces[key] = append(edgeFaces[key], i)
+		}
+	}
+
+	// 面積の大きい面から順に種にして、チャートを広げる
+	order := make([]int, len(im.faces))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return areas[order[i]] > areas[order[j]]
+	})
+
+	chartOf := make([]int, len(im.faces))
+	for i := range chartOf {
+		chartOf[i] = -1
+	}
+	var charts []*uvChart
+	for _, seed := range order {
+		if chartOf[seed] >= 0 {
+			continue
+		}
+
+		c := &uvChart{normal: normals[seed]}
+		if c.normal.LengthSq() == 0 {
+			c.normal = UnitY()
+		}
+		chartOf[seed] = len(charts)
+		queue := []int{seed}
+		for len(queue) > 0 {
+			f := queue[0]
+			queue = queue[1:]
+			c.faces = append(c.faces, f)
+			for k := 0; k < 3; k++ {
+				for _, g := range edgeFaces[indexEdgeKey(im.faces[f][k], im.faces[f][(k+1)%3])] {
+					if chartOf[g] >= 0 || normals[g].Dot(c.normal) < threshold {
+						continue
+					}
+					chartOf[g] = len(charts)
+					queue = append(queue, g)
+				}
+			}
+		}
+		charts = append(charts, c)
+	}
+
+	// チャートをそれぞれの法線に垂直な平面へ投影する。縮尺は全チャートで共通
+	total := 0.0
+	for _, c := range charts {
+		u, v := planarBasis(c.normal)
+		for _, f := range c.faces {
+			var uv [3]Vector3
+			for k, p := range im.faces[f] {
+				q := im.positions[p]
+				uv[k] = NewVector3(q.Dot(u), q.Dot(v), 0)
+			}
+			c.uvs = append(c.uvs, uv)
+		}
+		c.rotateToFit()
+		size := c.max.Sub(c.min)
+		total += size.X * size.Y
+	}
+
+	scale := packCharts(charts, math.Sqrt(total)*padding)
+	for _, c := range charts {
+		for i, f := range c.faces {
+			for k := 0; k < 3; k++ {
+				uv := c.uvs[i][k].Sub(c.min).Add(c.offset).MulScalar(scale)
+				im.corners[f][k].Uv = NewVector3(uv.X, uv.Y, 0)
+			}
+		}
+	}
+
+	for i, f := range m.Faces {
+		f.V1.Uv, f.V2.Uv, f.V3.Uv = im.corners[i][0].Uv, im.corners[i][1].Uv, im.corners[i][2].Uv
+	}
+	return len(charts)
+}
+
+type uvChart struct {
+	normal Vector3
+	faces  []int
+	uvs    [][3]Vector3
+
+	// 投影した座標の範囲と、アトラス上での左下の位置
+	min, max Vector3
+	offset   Vector3
+}
+
+// 外接する長方形の面積が最も小さくなる向きに回し、min, maxを求める
+func (c *uvChart) rotateToFit() {
+	bounds := func(angle float64) (Vector3, Vector3) {
+		sin, cos := math.Sincos(angle)
+		lo := NewVector3(math.Inf(1), math.Inf(1), 0)
+		hi := NewVector3(math.Inf(-1), math.Inf(-1), 0)
+		for _, uv := range c.uvs {
+			for _, p := range uv {
+				x, y := p.X*cos-p.Y*sin, p.X*sin+p.Y*cos
+				lo.X, lo.Y = math.Min(lo.X, x), math.Min(lo.Y, y)
+				hi.X, hi.Y = math.Max(hi.X, x), math.Max(hi.Y, y)
+			}
+		}
+		return lo, hi
+	}
+
+	// 90度回すと同じ長方形になるので、その範囲だけを調べる
+	best, bestArea := 0.0, math.Inf(1)
+	for i := 0; i < 18; i++ {
+		angle := float64(i) * math.Pi / 36
+		lo, hi := bounds(angle)
+		if area := (hi.X - lo.X) * (hi.Y - lo.Y); area < bestArea-1e-12 {
+			best, bestArea = angle, area
+		}
+	}
+
+	sin, cos := math.Sincos(best)
+	for i, uv := range c.uvs {
+		for k, p := range uv {
+			c.uvs[i][k] = NewVector3(p.X*cos-p.Y*sin, p.X*sin+p.Y*cos, 0)
+		}
+	}
+	c.min, c.max = bounds(0)
+}
+
+// 高さの順に並べたチャートを棚に左から詰めていき、全体を0から1に収める倍率を返す
+func packCharts(charts []*uvChart, padding float64) float64 {
+	sorted := append([]*uvChart(nil), charts...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].max.Y-sorted[i].min.Y > sorted[j].max.Y-sorted[j].min.Y
+	})
+
+	area, widest := 0.0, 0.0
+	for _, c := range sorted {
+		size := c.max.Sub(c.min)
+		area += (size.X + padding) * (size.Y + padding)
+		widest = math.Max(widest, size.X+2*padding)
+	}
+	limit := math.Max(math.Sqrt(area), widest)
+
+	x, y, shelf, width := padding, padding, 0.0, 0.0
+	for _, c := range sorted {
+		size := c.max.Sub(c.min)
+		if x+size.X+padding > limit && x > padding {
+			x = padding
+			y += shelf + padding
+			shelf = 0
+		}
+		c.offset = NewVector3(x, y, 0)
+		x += size.X + padding
+		shelf = math.Max(shelf, size.Y)
+		width = math.Max(width, x)
+	}
+	height := y + shelf + padding
+
+	extent := math.Max(width, height)
+	if extent <= 0 {
+		return 1
+	}
+	return 1 / extent
+}
@@ -0,0 +1,198 @@
+package poly
+
+import (
+	"math"
+
+	. "github.com/arata-nvm/poly/vecmath"
+)
+
+// 面はMesh.Facesの添字、辺と境界は座標で表す
+type ValidationReport struct {
+	NonManifoldEdges [][2]Vector3
+	BoundaryLoops    [][]Vector3
+	DegenerateFaces  []int
+	// 同じ辺を同じ向きにたどる隣り合った面の組
+	InconsistentWinding [][2]int
+	// 前に出てきた面と同じ頂点を持つ面
+	DuplicateFaces []int
+	NaNFaces       []int
+}
+
+// 閉じていて向きの揃った、きれいなメッシュならtrue
+func (r ValidationReport) OK() bool {
+	return len(r.NonManifoldEdges) == 0 &&
+		len(r.BoundaryLoops) == 0 &&
+		len(r.DegenerateFaces) == 0 &&
+		len(r.InconsistentWinding) == 0 &&
+		len(r.DuplicateFaces) == 0 &&
+		len(r.NaNFaces) == 0
+}
+
+func (m *Mesh) Validate() ValidationReport {
+	var r ValidationReport
+	t := m.Topology()
+
+	for _, e := range t.NonManifoldEdges() {
+		r.NonManifoldEdges = append(r.NonManifoldEdges, [2]Vector3{t.Positions[e[0]], t.Positions[e[1]]})
+	}
+
+	for _, loop := range t.BoundaryLoops() {
+		ps := make([]Vector3, len(loop))
+		for i, v := range loop {
+			ps[i] = t.Positions[v]
+		}
+		r.BoundaryLoops = append(r.BoundaryLoops, ps)
+	}
+
+	seen := make(map[[3]int]bool)
+	for i, f := range m.Faces {
+		if f.hasNaN() {
+			r.NaNFaces = append(r.NaNFaces, i)
+			continue
+		}
+		if f.IsDegenerate() {
+			r.DegenerateFaces = append(r.DegenerateFaces, i)
+		}
+
+		key := sortedFace(t.Faces[i])
+		if seen[key] {
+			r.DuplicateFaces = append(r.DuplicateFaces, i)
+		}
+		seen[key] = true
+	}
+
+	for h, he := range t.HalfEdges {
+		if he.Twin > h && he.Origin == t.HalfEdges[he.Twin].Origin {
+			r.InconsistentWinding = append(r.InconsistentWinding, [2]int{he.Face, t.HalfEdges[he.Twin].Face})
+		}
+	}
+
+	return r
+}
+
+// 面積がほぼ0の面(重なった頂点や一直線上の頂点を含む)
+func (f *Face) IsDegenerate() bool {
+	a, b, c := f.V1.Coordinates, f.V2.Coordinates, f.V3.Coordinates
+	longest := math.Max(b.Sub(a).LengthSq(), math.Max(c.Sub(b).LengthSq(), a.Sub(c).LengthSq()))
+	cross := b.Sub(a).Cross(c.Sub(a)).LengthSq()
+	return cross <= 1e-24*longest*longest
+}
+
+func (f *Face) hasNaN() bool {
+	for _, p := range []Vector3{f.V1.Coordinates, f.V2.Coordinates, f.V3.Coordinates} {
+		if math.IsNaN(p.X) || math.IsNaN(p.Y) || math.IsNaN(p.Z) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedFace(f [3]int) [3]int {
+	if f[0] > f[1] {
+		f[0], f[1] = f[1], f[0]
+	}
+	if f[1] > f[2] {
+		f[1], f[2] = f[2], f[1]
+	}
+	if f[0] > f[1] {
+		f[0], f[1] = f[1], f[0]
+	}
+	return f
+}
+
+// 面積がほぼ0の面と座標にNaNを含む面を取り除き、取り除いた数を返す
+func (m *Mesh) RemoveDegenerateFaces() int {
+	faces := m.Faces[:0]
+	for _, f := range m.Faces {
+		if !f.hasNaN() && !f.IsDegenerate() {
+			faces = append(faces, f)
+		}
+	}
+
+	removed := len(m.Faces) - len(faces)
+	if removed > 0 {
+		m.Faces = faces
+		m.Polygons = nil
+		if m.bvh != nil {
+			m.BuildBVH()
+		}
+	}
+	return removed
+}
+
+// 隣り合う面の巻き順を揃え、裏返した面の数を返す
+// 閉じた部分は体積が正になる(外向きになる)ようにする
+// 裏返した面の頂点法線は、新しい面の向きと逆を向いていれば反転する
+func (m *Mesh) FixWinding() int {
+	t := m.Topology()
+	flip := make([]bool, len(m.Faces))
+	visited := make([]bool, len(m.Faces))
+
+	for start := range m.Faces {
+		if visited[start] {
+			continue
+		}
+
+		// 幅優先探索で、隣の面と同じ辺を逆向きにたどるように向きを決める
+		component := []int{start}
+		visited[start] = true
+		closed := true
+		for i := 0; i < len(component); i++ {
+			f := component[i]
+			for k := 0; k < 3; k++ {
+				h := 3*f + k
+				if t.isBoundary(h) {
+					closed = false
+				}
+				twin := t.HalfEdges[h].Twin
+				if twin < 0 {
+					continue
+				}
+				g := t.HalfEdges[twin].Face
+				if visited[g] {
+					continue
+				}
+				same := t.HalfEdges[h].Origin == t.HalfEdges[twin].Origin
+				flip[g] = flip[f] != same
+				visited[g] = true
+				component = append(component, g)
+			}
+		}
+
+		if !closed {
+			continue
+		}
+		volume := 0.0
+		for _, f := range component {
+			a, b, c := m.Faces[f].V1.Coordinates, m.Faces[f].V2.Coordinates, m.Faces[f].V3.Coordinates
+			v := a.Dot(b.Cross(c))
+			if flip[f] {
+				v = -v
+			}
+			volume += v
+		}
+		if volume < 0 {
+			for _, f := range component {
+				flip[f] = !flip[f]
+			}
+		}
+	}
+
+	flipped := 0
+	for i, f := range m.Faces {
+		if flip[i] {
+			f.V2, f.V3 = f.V3, f.V2
+			n := f.V2.Coordinates.Sub(f.V1.Coordinates).Cross(f.V3.Coordinates.Sub(f.V1.Coordinates))
+			for _, v := range []*Vertex{&f.V1, &f.V2, &f.V3} {
+				if v.Normal.Dot(n) < 0 {
+					v.Normal = v.Normal.Negate()
+				}
+			}
+			flipped++
+		}
+	}
+	if flipped > 0 {
+		m.Polygons = nil
+	}
+	return flipped
+}
@@ -0,0 +1,103 @@
+package poly
+
+import (
+	"math"
+	"testing"
+
+	. "github.com/arata-nvm/poly/vecmath"
+)
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name string
+		edit func(m *Mesh)
+		// 0なら問題がないことを確かめる
+		boundaryLoops, inconsistent, degenerate, duplicate, nan int
+	}{
+		{"closed", func(m *Mesh) {}, 0, 0, 0, 0, 0},
+		{"open", func(m *Mesh) { m.Faces = m.Faces[1:] }, 1, 0, 0, 0, 0},
+		// 裏返した面は3つの隣の面それぞれと食い違う
+		{"flipped face", func(m *Mesh) {
+			f := m.Faces[0]
+			f.V2, f.V3 = f.V3, f.V2
+		}, 0, 3, 0, 0, 0},
+		{"duplicate face", func(m *Mesh) {
+			f := *m.Faces[0]
+			m.Faces = append(m.Faces, &f)
+		}, 0, 0, 0, 1, 0},
+		{"degenerate face", func(m *Mesh) {
+			f := *m.Faces[0]
+			f.V3.Coordinates = f.V1.Coordinates.Add(f.V2.Coordinates).MulScalar(0.5)
+			m.Faces[0] = &f
+		}, 1, 0, 1, 0, 0},
+		{"nan face", func(m *Mesh) {
+			f := *m.Faces[0]
+			f.V1.Coordinates.X = math.NaN()
+			m.Faces[0] = &f
+		}, 1, 0, 0, 0, 1},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := tetrahedron()
+			c.edit(m)
+			r := m.Validate()
+
+			got := []int{len(r.BoundaryLoops), len(r.InconsistentWinding), len(r.DegenerateFaces), len(r.DuplicateFaces), len(r.NaNFaces)}
+			want := []int{c.boundaryLoops, c.inconsistent, c.degenerate, c.duplicate, c.nan}
+			for i := range got {
+				if got[i] != want[i] {
+					t.Fatalf("got boundary/inconsistent/degenerate/duplicate/nan = %v, want %v (%+v)", got, want, r)
+				}
+			}
+			ok := c.boundaryLoops+c.inconsistent+c.degenerate+c.duplicate+c.nan == 0
+			if r.OK() != ok {
+				t.Fatalf("got OK() = %v, want %v", r.OK(), ok)
+			}
+		})
+	}
+}
+
+func TestFixWinding(t *testing.T) {
+	cases := []struct {
+		name string
+		mesh func() *Mesh
+		// 裏返す面
+		flip    []int
+		flipped int
+	}{
+		{"one face", tetrahedron, []int{2}, 1},
+		{"inside out", tetrahedron, []int{0, 1, 2, 3}, 4},
+		{"quad cube", quadCube, []int{1, 4, 7}, 3},
+		// 閉じていない部分は最初の面に向きを合わせる
+		{"open", func() *Mesh {
+			m := tetrahedron()
+			m.Faces = m.Faces[1:]
+			return m
+		}, []int{1}, 1},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := c.mesh()
+			volume := m.Volume()
+			for _, i := range c.flip {
+				f := m.Faces[i]
+				f.V2, f.V3 = f.V3, f.V2
+			}
+
+			if n := m.FixWinding(); n != c.flipped {
+				t.Fatalf("flipped %d faces, want %d", n, c.flipped)
+			}
+			if r := m.Validate(); len(r.InconsistentWinding) > 0 {
+				t.Fatalf("winding is still inconsistent: %v", r.InconsistentWinding)
+			}
+			if !ApproxEqual(m.Volume(), volume, 1e-9) {
+				t.Fatalf("got volume %v, want %v", m.Volume(), volume)
+			}
+			if n := m.FixWinding(); n != 0 {
+				t.Fatalf("second FixWinding flipped %d faces", n)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ type Vertex struct {
 	Coordinates Vector3
 	Uv          Vector3
 	Normal      Vector3
+	World       Vector3
 }
 
 func InterpolateVertex(v1, v2, v3 Vertex, w Vector3) Vertex {
@@ -13,6 +14,7 @@ func InterpolateVertex(v1, v2, v3 Vertex, w Vector3) Vertex {
 		Coordinates: InterpolateVector(v1.Coordinates, v2.Coordinates, v3.Coordinates, w),
 		Uv:          InterpolateVector(v1.Uv, v2.Uv, v3.Uv, w),
 		Normal:      InterpolateVector(v1.Normal, v2.Normal, v3.Normal, w),
+		World:       InterpolateVector(v1.World, v2.World, v3.World, w),
 	}
 }
 
@@ -0,0 +1,46 @@
+package poly
+
+import "image"
+
+// 座標は画面左下を原点とする(DrawPointやDrawLineと同じ)
+func (d *Device) SetViewport(x, y, width, height int) {
+	d.viewport = image.Rect(x, y, x+width, y+height)
+	d.updateProjection()
+}
+
+func (d *Device) Viewport() image.Rectangle {
+	return d.viewport
+}
+
+func (d *Device) ResetViewport() {
+	d.SetViewport(0, 0, d.framebuffer.Width, d.framebuffer.Height)
+}
+
+func (d *Device) SetScissor(x, y, width, height int) {
+	d.scissor = image.Rect(x, y, x+width, y+height)
+}
+
+func (d *Device) SetScissorTest(enabled bool) {
+	d.scissorTest = enabled
+}
+
+// 描画を許可する範囲(画面座標)を返す
+func (d *Device) drawableRect() image.Rectangle {
+	r := d.viewport.Intersect(image.Rect(0, 0, d.framebuffer.Width, d.framebuffer.Height))
+	if d.scissorTest {
+		r = r.Intersect(d.scissor)
+	}
+	return r
+}
+
+// シザー矩形内の画素の添字を順に渡す
+func (d *Device) forEachScissored(f func(index int)) {
+	fb := d.framebuffer
+	r := d.scissor.Intersect(image.Rect(0, 0, fb.Width, fb.Height))
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		row := fb.Height - y - 1
+		for x := r.Min.X; x < r.Max.X; x++ {
+			f(x + row*fb.Width)
+		}
+	}
+}
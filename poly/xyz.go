@@ -0,0 +1,121 @@
+package poly
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	. "github.com/arata-nvm/poly/vecmath"
+)
+
+// 1行に1点の "x y z" を並べたテキストの点群を読み込む。区切りは空白かカンマで、#で始まる行は無視する
+// 座標の後には3列ずつ色と法線を続けられる(どちらが先でもよい)
+// 列の意味はすべての行から決める。どの行でも長さがほぼ1の3列を法線、それ以外を色とみなす
+// 色は1より大きい値があれば0から255のsRGB、なければPLYの浮動小数点数の色と同じく0から1のリニアとみなす
+func LoadXyzPointCloud(filename string) *PointCloud {
+	f, err := os.Open(filename)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+
+	return parseXyz(f)
+}
+
+func parseXyz(r io.Reader) *PointCloud {
+	s := bufio.NewScanner(r)
+
+	var rows [][]float64
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+			continue
+		}
+
+		cols := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ' ' || r == '\t' || r == ','
+		})
+		values := make([]float64, len(cols))
+		for i, c := range cols {
+			v, err := strconv.ParseFloat(c, 64)
+			if err != nil {
+				panic(err)
+			}
+			values[i] = v
+		}
+		if len(values) < 3 {
+			continue
+		}
+		rows = append(rows, values)
+	}
+	if err := s.Err(); err != nil {
+		panic(err)
+	}
+
+	normalColumns, byteColor := xyzLayout(rows)
+
+	pc := NewPointCloud()
+	for _, values := range rows {
+		p := Point{Position: NewVector3(values[0], values[1], values[2]), Color: WHITE}
+		for i, normal := range normalColumns {
+			if 3+3*(i+1) > len(values) {
+				break
+			}
+			v := xyzTriple(values, i)
+			if normal {
+				p.Normal = v
+				continue
+			}
+			if byteColor {
+				v = v.DivScalar(255)
+				v = NewVector3(SRGBToLinear(v.X), SRGBToLinear(v.Y), SRGBToLinear(v.Z))
+			}
+			p.Color = NewColor(v.X, v.Y, v.Z, 1)
+		}
+		pc.Points = append(pc.Points, p)
+	}
+	return pc
+}
+
+// 座標の後の3列ずつが法線かどうかと、色が0から255で書かれているかを決める
+// 1行でも長さが1でない値や範囲外の値があれば、その3列は色とみなす
+func xyzLayout(rows [][]float64) ([]bool, bool) {
+	columns := 0
+	for _, values := range rows {
+		columns = Max(columns, (len(values)-3)/3)
+	}
+
+	normalColumns := make([]bool, columns)
+	for i := range normalColumns {
+		normalColumns[i] = true
+	}
+	for _, values := range rows {
+		for i := 0; 3+3*(i+1) <= len(values); i++ {
+			v := xyzTriple(values, i)
+			if math.Abs(v.Length()-1) >= 1e-2 || math.Abs(v.X) > 1 || math.Abs(v.Y) > 1 || math.Abs(v.Z) > 1 {
+				normalColumns[i] = false
+			}
+		}
+	}
+
+	byteColor := false
+	for _, values := range rows {
+		for i, normal := range normalColumns {
+			if normal || 3+3*(i+1) > len(values) {
+				continue
+			}
+			if v := xyzTriple(values, i); v.X > 1 || v.Y > 1 || v.Z > 1 {
+				byteColor = true
+			}
+		}
+	}
+	return normalColumns, byteColor
+}
+
+// 座標の後のi番目の3列
+func xyzTriple(values []float64, i int) Vector3 {
+	return NewVector3(values[3+3*i], values[4+3*i], values[5+3*i])
+}
@@ -0,0 +1,53 @@
+package poly
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/arata-nvm/poly/vecmath"
+)
+
+func TestParseXyz(t *testing.T) {
+	red := NewColor(1, 0, 0, 1)
+	half := SRGBToLinear(128.0 / 255)
+
+	cases := []struct {
+		name   string
+		src    string
+		normal Vector3
+		color  Color
+	}{
+		{"position only", "1 2 3\n", Zero(), WHITE},
+		{"comments and commas", "# header\n// note\n\n1,2,3\n", Zero(), WHITE},
+		{"normal", "1 2 3 0 0 1\n4 5 6 0.6 0.8 0\n", UnitZ(), WHITE},
+		// 0から255の色はsRGBとみなす
+		{"byte color", "1 2 3 255 128 0\n4 5 6 0 0 0\n", Zero(), NewColor(1, half, 0, 1)},
+		// どの行でも長さ1の3列を法線とみなすので、色と法線の順はどちらでもよい
+		{"normal then color", "1 2 3 0 0 1 1 0 0\n4 5 6 1 0 0 0.5 0.5 0.5\n", UnitZ(), red},
+		{"color then normal", "1 2 3 1 0 0 0 0 1\n4 5 6 0.5 0.5 0.5 1 0 0\n", UnitZ(), red},
+		// 1行目だけ見ると法線に見えるが、2行目が長さ1でないので色になる
+		{"unit color", "1 2 3 1 0 0\n4 5 6 0.5 0.5 0.5\n", Zero(), red},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			pc := parseXyz(strings.NewReader(c.src))
+			if len(pc.Points) == 0 {
+				t.Fatal("no points")
+			}
+
+			p := pc.Points[0]
+			if p.Position != NewVector3(1, 2, 3) {
+				t.Fatalf("got position %v, want (1, 2, 3)", p.Position)
+			}
+			if !p.Normal.ApproxEqual(c.normal, 1e-12) {
+				t.Fatalf("got normal %v, want %v", p.Normal, c.normal)
+			}
+			for k, pair := range [][2]float64{{p.Color.R, c.color.R}, {p.Color.G, c.color.G}, {p.Color.B, c.color.B}, {p.Color.A, c.color.A}} {
+				if !ApproxEqual(pair[0], pair[1], 1e-12) {
+					t.Fatalf("color channel %d is %v, want %v", k, pair[0], pair[1])
+				}
+			}
+		})
+	}
+}
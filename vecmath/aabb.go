@@ -0,0 +1,81 @@
+package vecmath
+
+import "math"
+
+type AABB struct {
+	Min Vector3
+	Max Vector3
+}
+
+func NewAABB(min, max Vector3) AABB {
+	return AABB{Min: min, Max: max}
+}
+
+// 何も含まない箱。Extendで点を加えていく
+func EmptyAABB() AABB {
+	inf := math.Inf(1)
+	return AABB{
+		Min: NewVector3(inf, inf, inf),
+		Max: NewVector3(-inf, -inf, -inf),
+	}
+}
+
+func (b AABB) IsEmpty() bool {
+	return b.Min.X > b.Max.X || b.Min.Y > b.Max.Y || b.Min.Z > b.Max.Z
+}
+
+func (b AABB) Extend(p Vector3) AABB {
+	return AABB{
+		Min: NewVector3(math.Min(b.Min.X, p.X), math.Min(b.Min.Y, p.Y), math.Min(b.Min.Z, p.Z)),
+		Max: NewVector3(math.Max(b.Max.X, p.X), math.Max(b.Max.Y, p.Y), math.Max(b.Max.Z, p.Z)),
+	}
+}
+
+func (b1 AABB) Union(b2 AABB) AABB {
+	return b1.Extend(b2.Min).Extend(b2.Max)
+}
+
+func (b AABB) Center() Vector3 {
+	return b.Min.Add(b.Max).MulScalar(0.5)
+}
+
+func (b AABB) Size() Vector3 {
+	return b.Max.Sub(b.Min)
+}
+
+func (b AABB) SurfaceArea() float64 {
+	if b.IsEmpty() {
+		return 0
+	}
+	s := b.Size()
+	return 2 * (s.X*s.Y + s.Y*s.Z + s.Z*s.X)
+}
+
+func (b AABB) Contains(p Vector3) bool {
+	return p.X >= b.Min.X && p.X <= b.Max.X &&
+		p.Y >= b.Min.Y && p.Y <= b.Max.Y &&
+		p.Z >= b.Min.Z && p.Z <= b.Max.Z
+}
+
+// 8つの頂点を変換し、それらを囲む箱を返す
+func (b AABB) Transform(m Matrix4) AABB {
+	if b.IsEmpty() {
+		return b
+	}
+
+	r := EmptyAABB()
+	for i := 0; i < 8; i++ {
+		p := b.Min
+		if i&1 != 0 {
+			p.X = b.Max.X
+		}
+		if i&2 != 0 {
+			p.Y = b.Max.Y
+		}
+		if i&4 != 0 {
+			p.Z = b.Max.Z
+		}
+		r = r.Extend(m.MulVector(p))
+	}
+	return r
+}
@@ -0,0 +1,178 @@
+package vecmath
+
+const (
+	bvhLeafSize = 4
+	bvhBins     = 12
+)
+
+type bvhNode struct {
+	bounds      AABB
+	left, right int
+	start       int
+	count       int
+}
+
+// 箱の集合に対するバウンディングボリューム階層
+// 表面積ヒューリスティック(SAH)で分割する
+type BVH struct {
+	nodes   []bvhNode
+	indices []int
+}
+
+func NewBVH(bounds []AABB) *BVH {
+	b := &BVH{
+		indices: make([]int, len(bounds)),
+	}
+	for i := range b.indices {
+		b.indices[i] = i
+	}
+
+	centers := make([]Vector3, len(bounds))
+	for i, bb := range bounds {
+		centers[i] = bb.Center()
+	}
+
+	if len(bounds) > 0 {
+		b.build(bounds, centers, 0, len(bounds))
+	}
+	return b
+}
+
+func (b *BVH) Bounds() AABB {
+	if len(b.nodes) == 0 {
+		return EmptyAABB()
+	}
+	return b.nodes[0].bounds
+}
+
+// testを満たす節だけを辿り、葉に含まれる要素の番号をvisitに渡す
+func (b *BVH) Query(test func(AABB) bool, visit func(index int)) {
+	if len(b.nodes) == 0 {
+		return
+	}
+
+	stack := []int{0}
+	for len(stack) > 0 {
+		n := &b.nodes[stack[len(stack)-1]]
+		stack = stack[:len(stack)-1]
+		if !test(n.bounds) {
+			continue
+		}
+
+		if n.count > 0 {
+			for _, i := range b.indices[n.start : n.start+n.count] {
+				visit(i)
+			}
+			continue
+		}
+		stack = append(stack, n.right, n.left)
+	}
+}
+
+func (b *BVH) build(bounds []AABB, centers []Vector3, start, end int) int {
+	node := bvhNode{bounds: EmptyAABB()}
+	centroidBounds := EmptyAABB()
+	for _, i := range b.indices[start:end] {
+		node.bounds = node.bounds.Union(bounds[i])
+		centroidBounds = centroidBounds.Extend(centers[i])
+	}
+
+	id := len(b.nodes)
+	b.nodes = append(b.nodes, node)
+
+	count := end - start
+	axis, split, ok := b.findSplit(bounds, centers, start, end, node.bounds, centroidBounds)
+	if count <= bvhLeafSize || !ok {
+		b.nodes[id].start = start
+		b.nodes[id].count = count
+		return id
+	}
+
+	mid := b.partition(centers, start, end, axis, split)
+	if mid == start || mid == end {
+		mid = start + count/2
+	}
+
+	left := b.build(bounds, centers, start, mid)
+	right := b.build(bounds, centers, mid, end)
+	b.nodes[id].left = left
+	b.nodes[id].right = right
+	return id
+}
+
+func (b *BVH) findSplit(bounds []AABB, centers []Vector3, start, end int, nodeBounds, centroidBounds AABB) (int, float64, bool) {
+	size := centroidBounds.Size()
+	bestAxis, bestSplit := -1, 0.0
+	bestCost := float64(end-start) * nodeBounds.SurfaceArea()
+
+	for axis := 0; axis < 3; axis++ {
+		extent := component(size, axis)
+		if extent <= 0 {
+			continue
+		}
+		min := component(centroidBounds.Min, axis)
+
+		var binBounds [bvhBins]AABB
+		var binCounts [bvhBins]int
+		for i := range binBounds {
+			binBounds[i] = EmptyAABB()
+		}
+		for _, i := range b.indices[start:end] {
+			k := int(float64(bvhBins) * (component(centers[i], axis) - min) / extent)
+			if k >= bvhBins {
+				k = bvhBins - 1
+			}
+			binCounts[k]++
+			binBounds[k] = binBounds[k].Union(bounds[i])
+		}
+
+		for k := 1; k < bvhBins; k++ {
+			lb, rb := EmptyAABB(), EmptyAABB()
+			lc, rc := 0, 0
+			for j := 0; j < k; j++ {
+				lb = lb.Union(binBounds[j])
+				lc += binCounts[j]
+			}
+			for j := k; j < bvhBins; j++ {
+				rb = rb.Union(binBounds[j])
+				rc += binCounts[j]
+			}
+			if lc == 0 || rc == 0 {
+				continue
+			}
+
+			cost := float64(lc)*lb.SurfaceArea() + float64(rc)*rb.SurfaceArea()
+			if cost < bestCost {
+				bestAxis = axis
+				bestSplit = min + extent*float64(k)/bvhBins
+				bestCost = cost
+			}
+		}
+	}
+
+	return bestAxis, bestSplit, bestAxis >= 0
+}
+
+func (b *BVH) partition(centers []Vector3, start, end, axis int, split float64) int {
+	i, j := start, end-1
+	for i <= j {
+		if component(centers[b.indices[i]], axis) < split {
+			i++
+		} else {
+			b.indices[i], b.indices[j] = b.indices[j], b.indices[i]
+			j--
+		}
+	}
+	return i
+}
+
+func component(v Vector3, axis int) float64 {
+	switch axis {
+	case 0:
+		return v.X
+	case 1:
+		return v.Y
+	default:
+		return v.Z
+	}
+}
@@ -0,0 +1,69 @@
+package vecmath
+
+type Plane struct {
+	Normal Vector3
+	D      float64
+}
+
+// 法線側が正になる符号付き距離
+func (p Plane) Distance(v Vector3) float64 {
+	return p.Normal.Dot(v) + p.D
+}
+
+type ViewFrustum struct {
+	Planes [6]Plane
+}
+
+// 射影行列(とビュー・モデル行列の積)から視錐台の6平面を取り出す
+// 得られる平面は行列に入力する座標系で表される
+func FrustumFromMatrix(m Matrix4) ViewFrustum {
+	row := func(a, b, c, d float64) Plane {
+		n := NewVector3(a, b, c)
+		l := n.Length()
+		return Plane{Normal: n.DivScalar(l), D: d / l}
+	}
+
+	return ViewFrustum{
+		Planes: [6]Plane{
+			row(m.M30+m.M00, m.M31+m.M01, m.M32+m.M02, m.M33+m.M03),
+			row(m.M30-m.M00, m.M31-m.M01, m.M32-m.M02, m.M33-m.M03),
+			row(m.M30+m.M10, m.M31+m.M11, m.M32+m.M12, m.M33+m.M13),
+			row(m.M30-m.M10, m.M31-m.M11, m.M32-m.M12, m.M33-m.M13),
+			row(m.M30+m.M20, m.M31+m.M21, m.M32+m.M22, m.M33+m.M23),
+			row(m.M30-m.M20, m.M31-m.M21, m.M32-m.M22, m.M33-m.M23),
+		},
+	}
+}
+
+func (f ViewFrustum) IntersectsAABB(b AABB) bool {
+	if b.IsEmpty() {
+		return false
+	}
+
+	for _, p := range f.Planes {
+		// 法線方向に最も進んだ頂点が外側なら箱全体が外側
+		v := b.Min
+		if p.Normal.X >= 0 {
+			v.X = b.Max.X
+		}
+		if p.Normal.Y >= 0 {
+			v.Y = b.Max.Y
+		}
+		if p.Normal.Z >= 0 {
+			v.Z = b.Max.Z
+		}
+		if p.Distance(v) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (f ViewFrustum) IntersectsSphere(s Sphere) bool {
+	for _, p := range f.Planes {
+		if p.Distance(s.Center) < -s.Radius {
+			return false
+		}
+	}
+	return true
+}
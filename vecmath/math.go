@@ -41,3 +41,20 @@ func Clamp(value, min, max float64) float64 {
 func Interpolate(min, max, t float64) float64 {
 	return min + (max-min)*Clamp(t, 0, 1)
 }
+
+// 行列式detが0か有限でないか、大きさの上限bound(各行や各列の長さの積)に比べて十分小さければtrue
+func isSingular(det, bound float64) bool {
+	if det == 0 || math.IsNaN(det) || math.IsInf(det, 0) {
+		return true
+	}
+	return math.Abs(det) <= 1e-12*bound
+}
+
+// 絶対誤差と相対誤差のどちらかがeps以内なら等しいとみなす
+func ApproxEqual(a, b, eps float64) bool {
+	if a == b {
+		return true
+	}
+	diff := math.Abs(a - b)
+	return diff <= eps || diff <= eps*math.Max(math.Abs(a), math.Abs(b))
+}
@@ -0,0 +1,116 @@
+package vecmath
+
+import "math"
+
+type Matrix3 struct {
+	M00, M01, M02 float64
+	M10, M11, M12 float64
+	M20, M21, M22 float64
+}
+
+func Identity3() Matrix3 {
+	return Matrix3{
+		1, 0, 0,
+		0, 1, 0,
+		0, 0, 1,
+	}
+}
+
+func (m1 Matrix3) Add(m2 Matrix3) Matrix3 {
+	return Matrix3{
+		m1.M00 + m2.M00, m1.M01 + m2.M01, m1.M02 + m2.M02,
+		m1.M10 + m2.M10, m1.M11 + m2.M11, m1.M12 + m2.M12,
+		m1.M20 + m2.M20, m1.M21 + m2.M21, m1.M22 + m2.M22,
+	}
+}
+
+func (m1 Matrix3) Sub(m2 Matrix3) Matrix3 {
+	return Matrix3{
+		m1.M00 - m2.M00, m1.M01 - m2.M01, m1.M02 - m2.M02,
+		m1.M10 - m2.M10, m1.M11 - m2.M11, m1.M12 - m2.M12,
+		m1.M20 - m2.M20, m1.M21 - m2.M21, m1.M22 - m2.M22,
+	}
+}
+
+func (m1 Matrix3) Mul(m2 Matrix3) Matrix3 {
+	return Matrix3{
+		m1.M00*m2.M00 + m1.M01*m2.M10 + m1.M02*m2.M20,
+		m1.M00*m2.M01 + m1.M01*m2.M11 + m1.M02*m2.M21,
+		m1.M00*m2.M02 + m1.M01*m2.M12 + m1.M02*m2.M22,
+		m1.M10*m2.M00 + m1.M11*m2.M10 + m1.M12*m2.M20,
+		m1.M10*m2.M01 + m1.M11*m2.M11 + m1.M12*m2.M21,
+		m1.M10*m2.M02 + m1.M11*m2.M12 + m1.M12*m2.M22,
+		m1.M20*m2.M00 + m1.M21*m2.M10 + m1.M22*m2.M20,
+		m1.M20*m2.M01 + m1.M21*m2.M11 + m1.M22*m2.M21,
+		m1.M20*m2.M02 + m1.M21*m2.M12 + m1.M22*m2.M22,
+	}
+}
+
+func (m1 Matrix3) MulScalar(f float64) Matrix3 {
+	return Matrix3{
+		m1.M00 * f, m1.M01 * f, m1.M02 * f,
+		m1.M10 * f, m1.M11 * f, m1.M12 * f,
+		m1.M20 * f, m1.M21 * f, m1.M22 * f,
+	}
+}
+
+func (m1 Matrix3) MulVector(v Vector3) Vector3 {
+	return Vector3{
+		m1.M00*v.X + m1.M01*v.Y + m1.M02*v.Z,
+		m1.M10*v.X + m1.M11*v.Y + m1.M12*v.Z,
+		m1.M20*v.X + m1.M21*v.Y + m1.M22*v.Z,
+	}
+}
+
+func (m1 Matrix3) Transpose() Matrix3 {
+	return Matrix3{
+		m1.M00, m1.M10, m1.M20,
+		m1.M01, m1.M11, m1.M21,
+		m1.M02, m1.M12, m1.M22,
+	}
+}
+
+func (m1 Matrix3) Determinant() float64 {
+	return m1.M00*(m1.M11*m1.M22-m1.M12*m1.M21) -
+		m1.M01*(m1.M10*m1.M22-m1.M12*m1.M20) +
+		m1.M02*(m1.M10*m1.M21-m1.M11*m1.M20)
+}
+
+// 行列式が各行(各列)の長さの積に比べて0に近い場合はfalseを返す
+// 全体の大きさによらないので、小さなスケールの行列も逆行列を持つ
+func (m1 Matrix3) Inverse() (Matrix3, bool) {
+	det := m1.Determinant()
+	rows := NewVector3(m1.M00, m1.M01, m1.M02).Length() *
+		NewVector3(m1.M10, m1.M11, m1.M12).Length() *
+		NewVector3(m1.M20, m1.M21, m1.M22).Length()
+	cols := NewVector3(m1.M00, m1.M10, m1.M20).Length() *
+		NewVector3(m1.M01, m1.M11, m1.M21).Length() *
+		NewVector3(m1.M02, m1.M12, m1.M22).Length()
+	if isSingular(det, math.Min(rows, cols)) {
+		return Matrix3{}, false
+	}
+
+	adj := Matrix3{
+		m1.M11*m1.M22 - m1.M12*m1.M21,
+		m1.M02*m1.M21 - m1.M01*m1.M22,
+		m1.M01*m1.M12 - m1.M02*m1.M11,
+		m1.M12*m1.M20 - m1.M10*m1.M22,
+		m1.M00*m1.M22 - m1.M02*m1.M20,
+		m1.M02*m1.M10 - m1.M00*m1.M12,
+		m1.M10*m1.M21 - m1.M11*m1.M20,
+		m1.M01*m1.M20 - m1.M00*m1.M21,
+		m1.M00*m1.M11 - m1.M01*m1.M10,
+	}
+	return adj.MulScalar(1 / det), true
+}
+
+func (m1 Matrix3) ApproxEqual(m2 Matrix3, eps float64) bool {
+	a := [...]float64{m1.M00, m1.M01, m1.M02, m1.M10, m1.M11, m1.M12, m1.M20, m1.M21, m1.M22}
+	b := [...]float64{m2.M00, m2.M01, m2.M02, m2.M10, m2.M11, m2.M12, m2.M20, m2.M21, m2.M22}
+	for i := range a {
+		if !ApproxEqual(a[i], b[i], eps) {
+			return false
+		}
+	}
+	return true
+}
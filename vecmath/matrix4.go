@@ -60,12 +60,12 @@ func RotateAxis(axis Vector3, theta float64) Matrix4 {
 	c2 := 1.0 - c
 	return Matrix4{
 		v.X*v.X*c2 + c,
-		v.X*v.Y*c2 - v.X*s,
-		v.X*v.Y*c2 + v.X*s,
+		v.X*v.Y*c2 - v.Z*s,
+		v.X*v.Z*c2 + v.Y*s,
 		0,
-		v.Y*v.X*c2 + v.X*s,
+		v.Y*v.X*c2 + v.Z*s,
 		v.Y*v.Y*c2 + c,
-		v.Y*v.X*c2 - v.X*s,
+		v.Y*v.Z*c2 - v.X*s,
 		0,
 		v.Z*v.X*c2 - v.Y*s,
 		v.Z*v.Y*c2 + v.X*s,
@@ -187,3 +187,214 @@ func (m1 Matrix4) MulVector(v Vector3) Vector3 {
 		m1.M20*v.X + m1.M21*v.Y + m1.M22*v.Z + m1.M23,
 	}
 }
+
+func (m1 Matrix4) MulVector4(v Vector4) Vector4 {
+	return Vector4{
+		m1.M00*v.X + m1.M01*v.Y + m1.M02*v.Z + m1.M03*v.W,
+		m1.M10*v.X + m1.M11*v.Y + m1.M12*v.Z + m1.M13*v.W,
+		m1.M20*v.X + m1.M21*v.Y + m1.M22*v.Z + m1.M23*v.W,
+		m1.M30*v.X + m1.M31*v.Y + m1.M32*v.Z + m1.M33*v.W,
+	}
+}
+
+func (m1 Matrix4) Transpose() Matrix4 {
+	return Matrix4{
+		m1.M00, m1.M10, m1.M20, m1.M30,
+		m1.M01, m1.M11, m1.M21, m1.M31,
+		m1.M02, m1.M12, m1.M22, m1.M32,
+		m1.M03, m1.M13, m1.M23, m1.M33,
+	}
+}
+
+func (m1 Matrix4) Matrix3() Matrix3 {
+	return Matrix3{
+		m1.M00, m1.M01, m1.M02,
+		m1.M10, m1.M11, m1.M12,
+		m1.M20, m1.M21, m1.M22,
+	}
+}
+
+// 法線の変換に使う左上3x3の逆転置行列
+func (m1 Matrix4) NormalMatrix() Matrix3 {
+	inv, ok := m1.Matrix3().Inverse()
+	if !ok {
+		return m1.Matrix3()
+	}
+	return inv.Transpose()
+}
+
+func (m1 Matrix4) Determinant() float64 {
+	s0 := m1.M00*m1.M11 - m1.M10*m1.M01
+	s1 := m1.M00*m1.M12 - m1.M10*m1.M02
+	s2 := m1.M00*m1.M13 - m1.M10*m1.M03
+	s3 := m1.M01*m1.M12 - m1.M11*m1.M02
+	s4 := m1.M01*m1.M13 - m1.M11*m1.M03
+	s5 := m1.M02*m1.M13 - m1.M12*m1.M03
+
+	c5 := m1.M22*m1.M33 - m1.M32*m1.M23
+	c4 := m1.M21*m1.M33 - m1.M31*m1.M23
+	c3 := m1.M21*m1.M32 - m1.M31*m1.M22
+	c2 := m1.M20*m1.M33 - m1.M30*m1.M23
+	c1 := m1.M20*m1.M32 - m1.M30*m1.M22
+	c0 := m1.M20*m1.M31 - m1.M30*m1.M21
+
+	return s0*c5 - s1*c4 + s2*c3 + s3*c2 - s4*c1 + s5*c0
+}
+
+// 行列式が各行(各列)の長さの積に比べて0に近い場合はfalseを返す
+// 全体の大きさによらないので、小さなスケールの行列も逆行列を持つ
+func (m1 Matrix4) Inverse() (Matrix4, bool) {
+	s0 := m1.M00*m1.M11 - m1.M10*m1.M01
+	s1 := m1.M00*m1.M12 - m1.M10*m1.M02
+	s2 := m1.M00*m1.M13 - m1.M10*m1.M03
+	s3 := m1.M01*m1.M12 - m1.M11*m1.M02
+	s4 := m1.M01*m1.M13 - m1.M11*m1.M03
+	s5 := m1.M02*m1.M13 - m1.M12*m1.M03
+
+	c5 := m1.M22*m1.M33 - m1.M32*m1.M23
+	c4 := m1.M21*m1.M33 - m1.M31*m1.M23
+	c3 := m1.M21*m1.M32 - m1.M31*m1.M22
+	c2 := m1.M20*m1.M33 - m1.M30*m1.M23
+	c1 := m1.M20*m1.M32 - m1.M30*m1.M22
+	c0 := m1.M20*m1.M31 - m1.M30*m1.M21
+
+	det := s0*c5 - s1*c4 + s2*c3 + s3*c2 - s4*c1 + s5*c0
+	rows := NewVector4(m1.M00, m1.M01, m1.M02, m1.M03).Length() *
+		NewVector4(m1.M10, m1.M11, m1.M12, m1.M13).Length() *
+		NewVector4(m1.M20, m1.M21, m1.M22, m1.M23).Length() *
+		NewVector4(m1.M30, m1.M31, m1.M32, m1.M33).Length()
+	cols := NewVector4(m1.M00, m1.M10, m1.M20, m1.M30).Length() *
+		NewVector4(m1.M01, m1.M11, m1.M21, m1.M31).Length() *
+		NewVector4(m1.M02, m1.M12, m1.M22, m1.M32).Length() *
+		NewVector4(m1.M03, m1.M13, m1.M23, m1.M33).Length()
+	if isSingular(det, math.Min(rows, cols)) {
+		return Matrix4{}, false
+	}
+	inv := 1 / det
+
+	return Matrix4{
+		(m1.M11*c5 - m1.M12*c4 + m1.M13*c3) * inv,
+		(-m1.M01*c5 + m1.M02*c4 - m1.M03*c3) * inv,
+		(m1.M31*s5 - m1.M32*s4 + m1.M33*s3) * inv,
+		(-m1.M21*s5 + m1.M22*s4 - m1.M23*s3) * inv,
+
+		(-m1.M10*c5 + m1.M12*c2 - m1.M13*c1) * inv,
+		(m1.M00*c5 - m1.M02*c2 + m1.M03*c1) * inv,
+		(-m1.M30*s5 + m1.M32*s2 - m1.M33*s1) * inv,
+		(m1.M20*s5 - m1.M22*s2 + m1.M23*s1) * inv,
+
+		(m1.M10*c4 - m1.M11*c2 + m1.M13*c0) * inv,
+		(-m1.M00*c4 + m1.M01*c2 - m1.M03*c0) * inv,
+		(m1.M30*s4 - m1.M31*s2 + m1.M33*s0) * inv,
+		(-m1.M20*s4 + m1.M21*s2 - m1.M23*s0) * inv,
+
+		(-m1.M10*c3 + m1.M11*c1 - m1.M12*c0) * inv,
+		(m1.M00*c3 - m1.M01*c1 + m1.M02*c0) * inv,
+		(-m1.M30*s3 + m1.M31*s1 - m1.M32*s0) * inv,
+		(m1.M20*s3 - m1.M21*s1 + m1.M22*s0) * inv,
+	}, true
+}
+
+// 平行移動・回転・スケールに分解する
+// 行列式が負の場合はX軸のスケールを反転させて扱う
+// スケールが0の軸は、座標軸を他の軸と直交するように直した向きを回転として使う
+func (m1 Matrix4) Decompose() Transform {
+	axes := [3]Vector3{
+		NewVector3(m1.M00, m1.M10, m1.M20),
+		NewVector3(m1.M01, m1.M11, m1.M21),
+		NewVector3(m1.M02, m1.M12, m1.M22),
+	}
+
+	scale := NewVector3(axes[0].Length(), axes[1].Length(), axes[2].Length())
+	if m1.Matrix3().Determinant() < 0 {
+		scale.X = -scale.X
+	}
+
+	lengths := [3]float64{scale.X, scale.Y, scale.Z}
+	var zero []int
+	for i := range axes {
+		if lengths[i] == 0 {
+			zero = append(zero, i)
+			continue
+		}
+		axes[i] = axes[i].DivScalar(lengths[i])
+	}
+	if len(zero) > 0 {
+		axes = completeAxes(axes, zero)
+	}
+
+	x, y, z := axes[0], axes[1], axes[2]
+	r := Matrix4{
+		x.X, y.X, z.X, 0,
+		x.Y, y.Y, z.Y, 0,
+		x.Z, y.Z, z.Z, 0,
+		0, 0, 0, 1,
+	}
+
+	return Transform{
+		Translation: NewVector3(m1.M03, m1.M13, m1.M23),
+		Rotation:    QuaternionFromMatrix(r),
+		Scale:       scale,
+	}
+}
+
+// 長さ0の軸を座標軸で埋め、Gram-Schmidt法で他の軸と直交させる
+// 埋めた軸はスケール0で消えるので、右手系になるように向きを選んでよい
+func completeAxes(axes [3]Vector3, zero []int) [3]Vector3 {
+	units := [3]Vector3{UnitX(), UnitY(), UnitZ()}
+	isZero := func(i int) bool {
+		for _, z := range zero {
+			if z == i {
+				return true
+			}
+		}
+		return false
+	}
+
+	var done []Vector3
+	for i := range axes {
+		if !isZero(i) {
+			done = append(done, axes[i])
+		}
+	}
+	for _, i := range zero {
+		for k := 0; k < 3; k++ {
+			v := units[(i+k)%3]
+			for _, d := range done {
+				v = v.Sub(d.MulScalar(v.Dot(d)))
+			}
+			if v.Length() > 1e-6 {
+				axes[i] = v.Normalize()
+				done = append(done, axes[i])
+				break
+			}
+		}
+	}
+
+	if axes[0].Dot(axes[1].Cross(axes[2])) < 0 {
+		last := zero[len(zero)-1]
+		axes[last] = axes[last].Negate()
+	}
+	return axes
+}
+
+func (m1 Matrix4) ApproxEqual(m2 Matrix4, eps float64) bool {
+	a := [...]float64{
+		m1.M00, m1.M01, m1.M02, m1.M03,
+		m1.M10, m1.M11, m1.M12, m1.M13,
+		m1.M20, m1.M21, m1.M22, m1.M23,
+		m1.M30, m1.M31, m1.M32, m1.M33,
+	}
+	b := [...]float64{
+		m2.M00, m2.M01, m2.M02, m2.M03,
+		m2.M10, m2.M11, m2.M12, m2.M13,
+		m2.M20, m2.M21, m2.M22, m2.M23,
+		m2.M30, m2.M31, m2.M32, m2.M33,
+	}
+	for i := range a {
+		if !ApproxEqual(a[i], b[i], eps) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,227 @@
+package vecmath
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+const trials = 1000
+
+func randomMatrix4(r *rand.Rand) Matrix4 {
+	f := func() float64 { return r.Float64()*4 - 2 }
+	return Matrix4{
+		f(), f(), f(), f(),
+		f(), f(), f(), f(),
+		f(), f(), f(), f(),
+		f(), f(), f(), f(),
+	}
+}
+
+func randomVector3(r *rand.Rand) Vector3 {
+	return NewVector3(r.Float64()*4-2, r.Float64()*4-2, r.Float64()*4-2)
+}
+
+func randomUnitVector3(r *rand.Rand) Vector3 {
+	for {
+		v := randomVector3(r)
+		if l := v.Length(); l > 0.1 {
+			return v.DivScalar(l)
+		}
+	}
+}
+
+func randomQuaternion(r *rand.Rand) Quaternion {
+	return QuaternionFromAxisAngle(randomUnitVector3(r), r.Float64()*2*math.Pi)
+}
+
+// 軸ごとに0.5から2の大きさで、符号はランダム
+func randomScale(r *rand.Rand) Vector3 {
+	s := func() float64 {
+		v := 0.5 + r.Float64()*1.5
+		if r.Intn(2) == 0 {
+			return -v
+		}
+		return v
+	}
+	return NewVector3(s(), s(), s())
+}
+
+func TestMatrix4Inverse(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < trials; i++ {
+		m := randomMatrix4(r)
+		if math.Abs(m.Determinant()) < 1e-3 {
+			continue
+		}
+		inv, ok := m.Inverse()
+		if !ok {
+			t.Fatalf("Inverse failed for %v", m)
+		}
+		if p := m.Mul(inv); !p.ApproxEqual(Identity(), 1e-9) {
+			t.Fatalf("M * M^-1 = %v, want identity", p)
+		}
+		if p := inv.Mul(m); !p.ApproxEqual(Identity(), 1e-9) {
+			t.Fatalf("M^-1 * M = %v, want identity", p)
+		}
+	}
+}
+
+func TestMatrix4InverseSingular(t *testing.T) {
+	m := Matrix4{
+		1, 2, 3, 4,
+		2, 4, 6, 8,
+		0, 1, 0, 1,
+		1, 0, 1, 0,
+	}
+	if _, ok := m.Inverse(); ok {
+		t.Fatal("Inverse of a singular matrix succeeded")
+	}
+}
+
+func TestMatrix4Determinant(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	for i := 0; i < trials; i++ {
+		a, b := randomMatrix4(r), randomMatrix4(r)
+		got, want := a.Mul(b).Determinant(), a.Determinant()*b.Determinant()
+		if !ApproxEqual(got, want, 1e-9) {
+			t.Fatalf("det(AB) = %v, want det(A)det(B) = %v", got, want)
+		}
+		if !ApproxEqual(a.Transpose().Determinant(), a.Determinant(), 1e-9) {
+			t.Fatalf("det(A^T) != det(A) for %v", a)
+		}
+	}
+	if d := Identity().Determinant(); d != 1 {
+		t.Fatalf("det(I) = %v", d)
+	}
+}
+
+func TestMatrix4Transpose(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	for i := 0; i < trials; i++ {
+		a, b := randomMatrix4(r), randomMatrix4(r)
+		if a.Transpose().Transpose() != a {
+			t.Fatalf("(A^T)^T != A for %v", a)
+		}
+		if !a.Mul(b).Transpose().ApproxEqual(b.Transpose().Mul(a.Transpose()), 1e-12) {
+			t.Fatalf("(AB)^T != B^T A^T")
+		}
+	}
+}
+
+func TestMatrix4Decompose(t *testing.T) {
+	r := rand.New(rand.NewSource(4))
+	for i := 0; i < trials; i++ {
+		m := NewTransform(randomVector3(r), randomQuaternion(r), randomScale(r)).Matrix()
+		d := m.Decompose()
+		if got := NewTransform(d.Translation, d.Rotation, d.Scale).Matrix(); !got.ApproxEqual(m, 1e-9) {
+			t.Fatalf("Decompose round trip = %v, want %v", got, m)
+		}
+	}
+}
+
+func TestMatrix4MulVector4(t *testing.T) {
+	r := rand.New(rand.NewSource(5))
+	for i := 0; i < trials; i++ {
+		m, v := randomMatrix4(r), randomVector3(r)
+		// w = 1なら平行移動を含むMulVectorと一致する
+		if got := m.MulVector4(NewVector4FromVec(v, 1)).XYZ(); !got.ApproxEqual(m.MulVector(v), 1e-12) {
+			t.Fatalf("MulVector4(v, 1) = %v, want %v", got, m.MulVector(v))
+		}
+		// w = 0なら平行移動を無視するTransformDirectionと一致する
+		if got := m.MulVector4(NewVector4FromVec(v, 0)).XYZ(); !got.ApproxEqual(TransformDirection(v, m), 1e-12) {
+			t.Fatalf("MulVector4(v, 0) = %v, want %v", got, TransformDirection(v, m))
+		}
+		// 行列の積と順番に掛けた結果が一致する
+		n := randomMatrix4(r)
+		x := NewVector4(r.Float64(), r.Float64(), r.Float64(), r.Float64())
+		if !m.Mul(n).MulVector4(x).ApproxEqual(m.MulVector4(n.MulVector4(x)), 1e-9) {
+			t.Fatalf("(MN)x != M(Nx)")
+		}
+	}
+}
+
+func TestTransformDirection(t *testing.T) {
+	r := rand.New(rand.NewSource(6))
+	for i := 0; i < trials; i++ {
+		q, s, v := randomQuaternion(r), randomScale(r), randomVector3(r)
+		withoutTranslation := NewTransform(Zero(), q, s).Matrix()
+		m := Translate(randomVector3(r)).Mul(withoutTranslation)
+		if got, want := TransformDirection(v, m), withoutTranslation.MulVector(v); !got.ApproxEqual(want, 1e-9) {
+			t.Fatalf("TransformDirection = %v, want %v", got, want)
+		}
+		if got := TransformDirection(v, Translate(randomVector3(r))); !got.ApproxEqual(v, 1e-12) {
+			t.Fatalf("TransformDirection moved a direction by translation: %v -> %v", v, got)
+		}
+	}
+}
+
+func TestRotateAxis(t *testing.T) {
+	r := rand.New(rand.NewSource(7))
+	for i := 0; i < trials; i++ {
+		axis, theta := randomUnitVector3(r), r.Float64()*2*math.Pi
+		m := RotateAxis(axis, theta)
+		if want := QuaternionFromAxisAngle(axis, theta).Matrix(); !m.ApproxEqual(want, 1e-9) {
+			t.Fatalf("RotateAxis(%v, %v) = %v, want %v", axis, theta, m, want)
+		}
+		if !ApproxEqual(m.Determinant(), 1, 1e-9) {
+			t.Fatalf("det(RotateAxis) = %v", m.Determinant())
+		}
+		if !m.MulVector(axis).ApproxEqual(axis, 1e-9) {
+			t.Fatalf("RotateAxis moved its own axis")
+		}
+	}
+
+	// 座標軸の周りの回転と一致する
+	for _, theta := range []float64{0.3, 1, 2.5} {
+		if !RotateAxis(UnitX(), theta).ApproxEqual(RotateX(theta), 1e-12) ||
+			!RotateAxis(UnitY(), theta).ApproxEqual(RotateY(theta), 1e-12) ||
+			!RotateAxis(UnitZ(), theta).ApproxEqual(RotateZ(theta), 1e-12) {
+			t.Fatalf("RotateAxis disagrees with RotateX/Y/Z at %v", theta)
+		}
+	}
+}
+
+func TestMatrix4InverseSmallScale(t *testing.T) {
+	for _, s := range []float64{1e-4, 1e-6, 1e6} {
+		m := Translate(NewVector3(1, 2, 3)).Mul(RotateAxis(UnitY(), 0.5)).Mul(Scale(NewVector3(s, s, s)))
+		inv, ok := m.Inverse()
+		if !ok {
+			t.Fatalf("Inverse rejected a uniform scale of %v", s)
+		}
+		if p := m.Mul(inv); !p.ApproxEqual(Identity(), 1e-9) {
+			t.Fatalf("M * M^-1 = %v at scale %v", p, s)
+		}
+		if _, ok := m.Matrix3().Inverse(); !ok {
+			t.Fatalf("Matrix3.Inverse rejected a uniform scale of %v", s)
+		}
+	}
+}
+
+func TestMatrix4DecomposeZeroScale(t *testing.T) {
+	q := QuaternionFromAxisAngle(NewVector3(1, 2, 3).Normalize(), 0.7)
+	cases := []Vector3{
+		NewVector3(0, 2, 3),
+		NewVector3(2, 0, 3),
+		NewVector3(2, 3, 0),
+		NewVector3(0, 0, 3),
+		NewVector3(0, 0, 0),
+	}
+	for _, s := range cases {
+		m := NewTransform(NewVector3(1, -1, 2), q, s).Matrix()
+		d := m.Decompose()
+		r := d.Rotation
+		if math.IsNaN(r.X) || math.IsNaN(r.Y) || math.IsNaN(r.Z) || math.IsNaN(r.W) {
+			t.Fatalf("scale %v: rotation is NaN", s)
+		}
+		if !ApproxEqual(r.Length(), 1, 1e-9) {
+			t.Fatalf("scale %v: rotation is not a unit quaternion: %v", s, r)
+		}
+		if got := NewTransform(d.Translation, d.Rotation, d.Scale).Matrix(); !got.ApproxEqual(m, 1e-9) {
+			t.Fatalf("scale %v: round trip = %v, want %v", s, got, m)
+		}
+	}
+	if r := Scale(Zero()).Decompose().Rotation; !r.ApproxEqual(IdentityQuaternion(), 1e-12) {
+		t.Fatalf("zero matrix rotation = %v, want identity", r)
+	}
+}
@@ -0,0 +1,174 @@
+package vecmath
+
+import "math"
+
+type Quaternion struct {
+	X, Y, Z, W float64
+}
+
+func NewQuaternion(x, y, z, w float64) Quaternion {
+	return Quaternion{x, y, z, w}
+}
+
+func IdentityQuaternion() Quaternion {
+	return Quaternion{0, 0, 0, 1}
+}
+
+func QuaternionFromAxisAngle(axis Vector3, theta float64) Quaternion {
+	v := axis.Normalize()
+	s := math.Sin(theta / 2)
+	return Quaternion{v.X * s, v.Y * s, v.Z * s, math.Cos(theta / 2)}
+}
+
+// RotateX(v.X) * RotateY(v.Y) * RotateZ(v.Z) と同じ回転を返す
+func QuaternionFromEuler(v Vector3) Quaternion {
+	qx := QuaternionFromAxisAngle(UnitX(), v.X)
+	qy := QuaternionFromAxisAngle(UnitY(), v.Y)
+	qz := QuaternionFromAxisAngle(UnitZ(), v.Z)
+	return qx.Mul(qy).Mul(qz)
+}
+
+// 回転行列(スケールを含まないもの)から変換する
+func QuaternionFromMatrix(m Matrix4) Quaternion {
+	trace := m.M00 + m.M11 + m.M22
+	var q Quaternion
+	switch {
+	case trace > 0:
+		s := math.Sqrt(trace+1) * 2
+		q = Quaternion{(m.M21 - m.M12) / s, (m.M02 - m.M20) / s, (m.M10 - m.M01) / s, s / 4}
+	case m.M00 > m.M11 && m.M00 > m.M22:
+		s := math.Sqrt(1+m.M00-m.M11-m.M22) * 2
+		q = Quaternion{s / 4, (m.M01 + m.M10) / s, (m.M02 + m.M20) / s, (m.M21 - m.M12) / s}
+	case m.M11 > m.M22:
+		s := math.Sqrt(1+m.M11-m.M00-m.M22) * 2
+		q = Quaternion{(m.M01 + m.M10) / s, s / 4, (m.M12 + m.M21) / s, (m.M02 - m.M20) / s}
+	default:
+		s := math.Sqrt(1+m.M22-m.M00-m.M11) * 2
+		q = Quaternion{(m.M02 + m.M20) / s, (m.M12 + m.M21) / s, s / 4, (m.M10 - m.M01) / s}
+	}
+	return q.Normalize()
+}
+
+// -Z軸がforwardを向くような回転を返す(LookAtと同じ向き)
+func LookRotation(forward, upward Vector3) Quaternion {
+	z := forward.Negate().Normalize()
+	x := upward.Cross(z).Normalize()
+	y := z.Cross(x)
+	return QuaternionFromMatrix(Matrix4{
+		x.X, y.X, z.X, 0,
+		x.Y, y.Y, z.Y, 0,
+		x.Z, y.Z, z.Z, 0,
+		0, 0, 0, 1,
+	})
+}
+
+func (q1 Quaternion) Add(q2 Quaternion) Quaternion {
+	return Quaternion{q1.X + q2.X, q1.Y + q2.Y, q1.Z + q2.Z, q1.W + q2.W}
+}
+
+func (q Quaternion) MulScalar(f float64) Quaternion {
+	return Quaternion{q.X * f, q.Y * f, q.Z * f, q.W * f}
+}
+
+func (q1 Quaternion) Mul(q2 Quaternion) Quaternion {
+	return Quaternion{
+		q1.W*q2.X + q1.X*q2.W + q1.Y*q2.Z - q1.Z*q2.Y,
+		q1.W*q2.Y - q1.X*q2.Z + q1.Y*q2.W + q1.Z*q2.X,
+		q1.W*q2.Z + q1.X*q2.Y - q1.Y*q2.X + q1.Z*q2.W,
+		q1.W*q2.W - q1.X*q2.X - q1.Y*q2.Y - q1.Z*q2.Z,
+	}
+}
+
+func (q1 Quaternion) Dot(q2 Quaternion) float64 {
+	return q1.X*q2.X + q1.Y*q2.Y + q1.Z*q2.Z + q1.W*q2.W
+}
+
+func (q Quaternion) Length() float64 {
+	return math.Sqrt(q.Dot(q))
+}
+
+func (q Quaternion) Normalize() Quaternion {
+	return q.MulScalar(1 / q.Length())
+}
+
+func (q Quaternion) Conjugate() Quaternion {
+	return Quaternion{-q.X, -q.Y, -q.Z, q.W}
+}
+
+func (q Quaternion) Inverse() Quaternion {
+	return q.Conjugate().MulScalar(1 / q.Dot(q))
+}
+
+func (q Quaternion) Rotate(v Vector3) Vector3 {
+	u := NewVector3(q.X, q.Y, q.Z)
+	t := u.Cross(v).MulScalar(2)
+	return v.Add(t.MulScalar(q.W)).Add(u.Cross(t))
+}
+
+func (q Quaternion) Matrix() Matrix4 {
+	xx, yy, zz := q.X*q.X, q.Y*q.Y, q.Z*q.Z
+	xy, xz, yz := q.X*q.Y, q.X*q.Z, q.Y*q.Z
+	wx, wy, wz := q.W*q.X, q.W*q.Y, q.W*q.Z
+	return Matrix4{
+		1 - 2*(yy+zz), 2 * (xy - wz), 2 * (xz + wy), 0,
+		2 * (xy + wz), 1 - 2*(xx+zz), 2 * (yz - wx), 0,
+		2 * (xz - wy), 2 * (yz + wx), 1 - 2*(xx+yy), 0,
+		0, 0, 0, 1,
+	}
+}
+
+func (q Quaternion) AxisAngle() (Vector3, float64) {
+	q = q.Normalize()
+	if q.W < 0 {
+		q = q.MulScalar(-1)
+	}
+	s := math.Sqrt(1 - q.W*q.W)
+	if s < 1e-9 {
+		return UnitX(), 0
+	}
+	return NewVector3(q.X/s, q.Y/s, q.Z/s), 2 * math.Acos(Clamp(q.W, -1, 1))
+}
+
+// QuaternionFromEulerの逆変換
+func (q Quaternion) Euler() Vector3 {
+	m := q.Normalize().Matrix()
+	sy := Clamp(m.M02, -1, 1)
+	y := math.Asin(sy)
+	if math.Abs(sy) < 0.9999999 {
+		return NewVector3(math.Atan2(-m.M12, m.M22), y, math.Atan2(-m.M01, m.M00))
+	}
+	return NewVector3(math.Atan2(m.M21, m.M11), y, 0)
+}
+
+func Slerp(q1, q2 Quaternion, t float64) Quaternion {
+	cos := q1.Dot(q2)
+	if cos < 0 {
+		q2 = q2.MulScalar(-1)
+		cos = -cos
+	}
+	if cos > 0.9995 {
+		return Nlerp(q1, q2, t)
+	}
+
+	theta := math.Acos(cos)
+	sin := math.Sin(theta)
+	a := math.Sin((1-t)*theta) / sin
+	b := math.Sin(t*theta) / sin
+	return q1.MulScalar(a).Add(q2.MulScalar(b))
+}
+
+func Nlerp(q1, q2 Quaternion, t float64) Quaternion {
+	if q1.Dot(q2) < 0 {
+		q2 = q2.MulScalar(-1)
+	}
+	return q1.MulScalar(1 - t).Add(q2.MulScalar(t)).Normalize()
+}
+
+// qと-qは同じ回転を表すので、どちらかが近ければ等しいとみなす
+func (q1 Quaternion) ApproxEqual(q2 Quaternion, eps float64) bool {
+	eq := func(a, b Quaternion) bool {
+		return ApproxEqual(a.X, b.X, eps) && ApproxEqual(a.Y, b.Y, eps) &&
+			ApproxEqual(a.Z, b.Z, eps) && ApproxEqual(a.W, b.W, eps)
+	}
+	return eq(q1, q2) || eq(q1, q2.MulScalar(-1))
+}
@@ -0,0 +1,114 @@
+package vecmath
+
+import "math"
+
+type Ray struct {
+	Origin    Vector3
+	Direction Vector3
+}
+
+func NewRay(origin, direction Vector3) Ray {
+	return Ray{Origin: origin, Direction: direction}
+}
+
+func (r Ray) At(t float64) Vector3 {
+	return r.Origin.Add(r.Direction.MulScalar(t))
+}
+
+// 方向ベクトルは正規化しないので、変換前後でtの値が変わらない
+func (r Ray) Transform(m Matrix4) Ray {
+	return Ray{
+		Origin:    m.MulVector(r.Origin),
+		Direction: TransformDirection(r.Direction, m),
+	}
+}
+
+// Möller–Trumboreの方法。裏面とも交差する
+// u, vはそれぞれb, cの重み(aの重みは1-u-v)
+func (r Ray) IntersectTriangle(a, b, c Vector3) (t, u, v float64, ok bool) {
+	const eps = 1e-12
+
+	e1 := b.Sub(a)
+	e2 := c.Sub(a)
+	p := r.Direction.Cross(e2)
+	det := e1.Dot(p)
+	if math.Abs(det) < eps {
+		return 0, 0, 0, false
+	}
+	inv := 1 / det
+
+	s := r.Origin.Sub(a)
+	u = s.Dot(p) * inv
+	if u < 0 || u > 1 {
+		return 0, 0, 0, false
+	}
+
+	q := s.Cross(e1)
+	v = r.Direction.Dot(q) * inv
+	if v < 0 || u+v > 1 {
+		return 0, 0, 0, false
+	}
+
+	t = e2.Dot(q) * inv
+	if t < 0 {
+		return 0, 0, 0, false
+	}
+	return t, u, v, true
+}
+
+// スラブ法。始点が箱の中にあるときtminは0になる
+func (r Ray) IntersectAABB(b AABB) (tmin, tmax float64, ok bool) {
+	if b.IsEmpty() {
+		return 0, 0, false
+	}
+
+	tmin, tmax = 0, math.Inf(1)
+	for axis := 0; axis < 3; axis++ {
+		o := component(r.Origin, axis)
+		d := component(r.Direction, axis)
+		min, max := component(b.Min, axis), component(b.Max, axis)
+
+		if d == 0 {
+			if o < min || o > max {
+				return 0, 0, false
+			}
+			continue
+		}
+
+		t1, t2 := (min-o)/d, (max-o)/d
+		if t1 > t2 {
+			t1, t2 = t2, t1
+		}
+		tmin = math.Max(tmin, t1)
+		tmax = math.Min(tmax, t2)
+		if tmin > tmax {
+			return 0, 0, false
+		}
+	}
+	return tmin, tmax, true
+}
+
+// 始点が球の中にあるときは出ていく点までの距離を返す
+func (r Ray) IntersectSphere(s Sphere) (float64, bool) {
+	oc := r.Origin.Sub(s.Center)
+	a := r.Direction.LengthSq()
+	if a == 0 {
+		return 0, false
+	}
+	b := oc.Dot(r.Direction)
+	c := oc.LengthSq() - s.Radius*s.Radius
+	disc := b*b - a*c
+	if disc < 0 {
+		return 0, false
+	}
+
+	sq := math.Sqrt(disc)
+	t := (-b - sq) / a
+	if t < 0 {
+		t = (-b + sq) / a
+	}
+	if t < 0 {
+		return 0, false
+	}
+	return t, true
+}
@@ -0,0 +1,27 @@
+package vecmath
+
+import "math"
+
+type Sphere struct {
+	Center Vector3
+	Radius float64
+}
+
+func NewSphere(center Vector3, radius float64) Sphere {
+	return Sphere{Center: center, Radius: radius}
+}
+
+func (s Sphere) Contains(p Vector3) bool {
+	return p.Sub(s.Center).LengthSq() <= s.Radius*s.Radius
+}
+
+// 半径は最も大きく拡大される軸に合わせる
+func (s Sphere) Transform(m Matrix4) Sphere {
+	sx := NewVector3(m.M00, m.M10, m.M20).Length()
+	sy := NewVector3(m.M01, m.M11, m.M21).Length()
+	sz := NewVector3(m.M02, m.M12, m.M22).Length()
+	return Sphere{
+		Center: m.MulVector(s.Center),
+		Radius: s.Radius * math.Max(sx, math.Max(sy, sz)),
+	}
+}
@@ -0,0 +1,64 @@
+package vecmath
+
+type Transform struct {
+	Translation Vector3
+	Rotation    Quaternion
+	Scale       Vector3
+}
+
+func NewTransform(translation Vector3, rotation Quaternion, scale Vector3) Transform {
+	return Transform{
+		Translation: translation,
+		Rotation:    rotation,
+		Scale:       scale,
+	}
+}
+
+func IdentityTransform() Transform {
+	return Transform{
+		Translation: Zero(),
+		Rotation:    IdentityQuaternion(),
+		Scale:       Unit(),
+	}
+}
+
+func (t Transform) Matrix() Matrix4 {
+	return Translate(t.Translation).Mul(t.Rotation.Matrix()).Mul(Scale(t.Scale))
+}
+
+func (t Transform) TransformPoint(v Vector3) Vector3 {
+	return t.Rotation.Rotate(v.Mul(t.Scale)).Add(t.Translation)
+}
+
+func (t Transform) TransformVector(v Vector3) Vector3 {
+	return t.Rotation.Rotate(v.Mul(t.Scale))
+}
+
+// t * childの順に適用する変換を返す
+// 非一様スケールと回転が混ざる場合はせん断が表現できないため近似になる
+func (t Transform) Compose(child Transform) Transform {
+	return Transform{
+		Translation: t.TransformPoint(child.Translation),
+		Rotation:    t.Rotation.Mul(child.Rotation).Normalize(),
+		Scale:       t.Scale.Mul(child.Scale),
+	}
+}
+
+// 一様スケールの場合のみ厳密な逆変換になる
+func (t Transform) Inverse() Transform {
+	s := Unit().Div(t.Scale)
+	r := t.Rotation.Inverse()
+	return Transform{
+		Translation: r.Rotate(t.Translation).Mul(s).Negate(),
+		Rotation:    r,
+		Scale:       s,
+	}
+}
+
+func LerpTransform(t1, t2 Transform, t float64) Transform {
+	return Transform{
+		Translation: t1.Translation.Add(t2.Translation.Sub(t1.Translation).MulScalar(t)),
+		Rotation:    Slerp(t1.Rotation, t2.Rotation, t),
+		Scale:       t1.Scale.Add(t2.Scale.Sub(t1.Scale).MulScalar(t)),
+	}
+}
@@ -0,0 +1,56 @@
+package vecmath
+
+import "math"
+
+type Vector2 struct {
+	X, Y float64
+}
+
+func NewVector2(x, y float64) Vector2 {
+	return Vector2{x, y}
+}
+
+func (v1 Vector2) Add(v2 Vector2) Vector2 {
+	return Vector2{v1.X + v2.X, v1.Y + v2.Y}
+}
+
+func (v1 Vector2) Sub(v2 Vector2) Vector2 {
+	return Vector2{v1.X - v2.X, v1.Y - v2.Y}
+}
+
+func (v1 Vector2) Mul(v2 Vector2) Vector2 {
+	return Vector2{v1.X * v2.X, v1.Y * v2.Y}
+}
+
+func (v1 Vector2) MulScalar(f float64) Vector2 {
+	return Vector2{v1.X * f, v1.Y * f}
+}
+
+func (v1 Vector2) Dot(v2 Vector2) float64 {
+	return v1.X*v2.X + v1.Y*v2.Y
+}
+
+func (v1 Vector2) Cross(v2 Vector2) float64 {
+	return v1.X*v2.Y - v1.Y*v2.X
+}
+
+func (v1 Vector2) Length() float64 {
+	return math.Sqrt(v1.X*v1.X + v1.Y*v1.Y)
+}
+
+func (v1 Vector2) LengthSq() float64 {
+	return v1.X*v1.X + v1.Y*v1.Y
+}
+
+func (v1 Vector2) Normalize() Vector2 {
+	invLen := 1 / v1.Length()
+	return Vector2{v1.X * invLen, v1.Y * invLen}
+}
+
+func (v1 Vector2) Negate() Vector2 {
+	return Vector2{-v1.X, -v1.Y}
+}
+
+func (v1 Vector2) ApproxEqual(v2 Vector2, eps float64) bool {
+	return ApproxEqual(v1.X, v2.X, eps) && ApproxEqual(v1.Y, v2.Y, eps)
+}
@@ -31,6 +31,14 @@ func TransformCoordinate(v Vector3, transform Matrix4) Vector3 {
 	return transform.MulVector(v).MulScalar(w)
 }
 
+func TransformDirection(v Vector3, transform Matrix4) Vector3 {
+	return Vector3{
+		transform.M00*v.X + transform.M01*v.Y + transform.M02*v.Z,
+		transform.M10*v.X + transform.M11*v.Y + transform.M12*v.Z,
+		transform.M20*v.X + transform.M21*v.Y + transform.M22*v.Z,
+	}
+}
+
 func NewVector3(x, y, z float64) Vector3 {
 	return Vector3{x, y, z}
 }
@@ -107,3 +115,7 @@ func (v1 Vector3) Clamp(min, max float64) Vector3 {
 func (v Vector3) Reflected(n Vector3) Vector3 {
 	return n.MulScalar(2 * v.Dot(n)).Sub(v).Normalize()
 }
+
+func (v1 Vector3) ApproxEqual(v2 Vector3, eps float64) bool {
+	return ApproxEqual(v1.X, v2.X, eps) && ApproxEqual(v1.Y, v2.Y, eps) && ApproxEqual(v1.Z, v2.Z, eps)
+}
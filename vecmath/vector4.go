@@ -0,0 +1,57 @@
+package vecmath
+
+import "math"
+
+type Vector4 struct {
+	X, Y, Z, W float64
+}
+
+func NewVector4(x, y, z, w float64) Vector4 {
+	return Vector4{x, y, z, w}
+}
+
+func NewVector4FromVec(v Vector3, w float64) Vector4 {
+	return Vector4{v.X, v.Y, v.Z, w}
+}
+
+func (v1 Vector4) Add(v2 Vector4) Vector4 {
+	return Vector4{v1.X + v2.X, v1.Y + v2.Y, v1.Z + v2.Z, v1.W + v2.W}
+}
+
+func (v1 Vector4) Sub(v2 Vector4) Vector4 {
+	return Vector4{v1.X - v2.X, v1.Y - v2.Y, v1.Z - v2.Z, v1.W - v2.W}
+}
+
+func (v1 Vector4) Mul(v2 Vector4) Vector4 {
+	return Vector4{v1.X * v2.X, v1.Y * v2.Y, v1.Z * v2.Z, v1.W * v2.W}
+}
+
+func (v1 Vector4) MulScalar(f float64) Vector4 {
+	return Vector4{v1.X * f, v1.Y * f, v1.Z * f, v1.W * f}
+}
+
+func (v1 Vector4) Dot(v2 Vector4) float64 {
+	return v1.X*v2.X + v1.Y*v2.Y + v1.Z*v2.Z + v1.W*v2.W
+}
+
+func (v1 Vector4) Length() float64 {
+	return math.Sqrt(v1.Dot(v1))
+}
+
+func (v1 Vector4) Normalize() Vector4 {
+	return v1.MulScalar(1 / v1.Length())
+}
+
+func (v1 Vector4) XYZ() Vector3 {
+	return Vector3{v1.X, v1.Y, v1.Z}
+}
+
+// wで割って3次元の座標に戻す
+func (v1 Vector4) Homogenize() Vector3 {
+	return v1.XYZ().MulScalar(1 / v1.W)
+}
+
+func (v1 Vector4) ApproxEqual(v2 Vector4, eps float64) bool {
+	return ApproxEqual(v1.X, v2.X, eps) && ApproxEqual(v1.Y, v2.Y, eps) &&
+		ApproxEqual(v1.Z, v2.Z, eps) && ApproxEqual(v1.W, v2.W, eps)
+}
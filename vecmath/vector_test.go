@@ -0,0 +1,141 @@
+package vecmath
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func randomMatrix3(r *rand.Rand) Matrix3 {
+	f := func() float64 { return r.Float64()*4 - 2 }
+	return Matrix3{
+		f(), f(), f(),
+		f(), f(), f(),
+		f(), f(), f(),
+	}
+}
+
+func TestMatrix3Inverse(t *testing.T) {
+	r := rand.New(rand.NewSource(11))
+	for i := 0; i < trials; i++ {
+		m := randomMatrix3(r)
+		if math.Abs(m.Determinant()) < 1e-3 {
+			continue
+		}
+		inv, ok := m.Inverse()
+		if !ok {
+			t.Fatalf("Inverse failed for %v", m)
+		}
+		if p := m.Mul(inv); !p.ApproxEqual(Identity3(), 1e-9) {
+			t.Fatalf("M * M^-1 = %v, want identity", p)
+		}
+	}
+}
+
+func TestMatrix3Algebra(t *testing.T) {
+	r := rand.New(rand.NewSource(12))
+	for i := 0; i < trials; i++ {
+		a, b := randomMatrix3(r), randomMatrix3(r)
+		if a.Transpose().Transpose() != a {
+			t.Fatalf("(A^T)^T != A for %v", a)
+		}
+		if !ApproxEqual(a.Mul(b).Determinant(), a.Determinant()*b.Determinant(), 1e-9) {
+			t.Fatalf("det(AB) != det(A)det(B)")
+		}
+		v := randomVector3(r)
+		if !a.Mul(b).MulVector(v).ApproxEqual(a.MulVector(b.MulVector(v)), 1e-9) {
+			t.Fatalf("(AB)v != A(Bv)")
+		}
+	}
+}
+
+// 法線行列で変換した法線は、変換した面の上の向きと直交したままになる
+func TestNormalMatrix(t *testing.T) {
+	r := rand.New(rand.NewSource(13))
+	for i := 0; i < trials; i++ {
+		m := NewTransform(randomVector3(r), randomQuaternion(r), randomScale(r)).Matrix()
+		n := randomUnitVector3(r)
+		tangent := n.Cross(randomUnitVector3(r))
+		got := m.NormalMatrix().MulVector(n).Dot(TransformDirection(tangent, m))
+		if math.Abs(got) > 1e-9 {
+			t.Fatalf("transformed normal is not perpendicular: %v", got)
+		}
+	}
+}
+
+func TestVector2(t *testing.T) {
+	r := rand.New(rand.NewSource(14))
+	for i := 0; i < trials; i++ {
+		a := NewVector2(r.Float64()*4-2, r.Float64()*4-2)
+		b := NewVector2(r.Float64()*4-2, r.Float64()*4-2)
+		if !a.Add(b).Sub(b).ApproxEqual(a, 1e-12) {
+			t.Fatalf("a + b - b != a")
+		}
+		if !ApproxEqual(a.Cross(b), -b.Cross(a), 1e-12) {
+			t.Fatalf("Cross is not antisymmetric")
+		}
+		if !ApproxEqual(a.Dot(a), a.LengthSq(), 1e-12) || !ApproxEqual(a.Length()*a.Length(), a.LengthSq(), 1e-12) {
+			t.Fatalf("Length/LengthSq/Dot disagree for %v", a)
+		}
+		if a.LengthSq() > 1e-6 && !ApproxEqual(a.Normalize().Length(), 1, 1e-12) {
+			t.Fatalf("Normalize(%v) is not unit length", a)
+		}
+		if !a.Negate().Add(a).ApproxEqual(NewVector2(0, 0), 1e-12) {
+			t.Fatalf("a + (-a) != 0")
+		}
+	}
+}
+
+func TestVector4(t *testing.T) {
+	r := rand.New(rand.NewSource(15))
+	for i := 0; i < trials; i++ {
+		v := randomVector3(r)
+		w := 0.5 + r.Float64()
+		h := NewVector4FromVec(v.MulScalar(w), w)
+		if !h.Homogenize().ApproxEqual(v, 1e-12) {
+			t.Fatalf("Homogenize(%v) = %v, want %v", h, h.Homogenize(), v)
+		}
+		if NewVector4FromVec(v, 1).XYZ() != v {
+			t.Fatalf("XYZ lost components")
+		}
+		a := NewVector4(r.Float64(), r.Float64(), r.Float64(), r.Float64())
+		if !ApproxEqual(a.Dot(a), a.Length()*a.Length(), 1e-12) {
+			t.Fatalf("Dot/Length disagree for %v", a)
+		}
+		if !a.Add(h).Sub(h).ApproxEqual(a, 1e-12) {
+			t.Fatalf("a + h - h != a")
+		}
+	}
+}
+
+func TestApproxEqual(t *testing.T) {
+	cases := []struct {
+		a, b, eps float64
+		want      bool
+	}{
+		{1, 1, 0, true},
+		{1, 1 + 1e-10, 1e-9, true},
+		{1, 1.1, 1e-9, false},
+		// 大きな値は相対誤差で比べる
+		{1e12, 1e12 + 1, 1e-9, true},
+		{1e12, 1.001e12, 1e-9, false},
+		// 0の近くは絶対誤差で比べる
+		{0, 1e-10, 1e-9, true},
+		{0, 1e-8, 1e-9, false},
+		{math.Inf(1), math.Inf(1), 1e-9, true},
+		{math.NaN(), math.NaN(), 1e-9, false},
+	}
+	for _, c := range cases {
+		if got := ApproxEqual(c.a, c.b, c.eps); got != c.want {
+			t.Errorf("ApproxEqual(%v, %v, %v) = %v, want %v", c.a, c.b, c.eps, got, c.want)
+		}
+	}
+
+	a := NewVector3(1, 2, 3)
+	if !a.ApproxEqual(a.Add(NewVector3(1e-12, 0, 0)), 1e-9) || a.ApproxEqual(a.Add(NewVector3(0, 0, 1e-3)), 1e-9) {
+		t.Error("Vector3.ApproxEqual is wrong")
+	}
+	if !Identity().ApproxEqual(Identity(), 0) || Identity().ApproxEqual(Translate(UnitX()), 1e-9) {
+		t.Error("Matrix4.ApproxEqual is wrong")
+	}
+}